@@ -0,0 +1,537 @@
+package snowy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a stored response. RequestHeader holds the values of the headers
+// listed in the response Vary header, as sent on the request that stored it.
+type CacheEntry struct {
+	StatusCode    int
+	Header        http.Header
+	Body          []byte
+	RequestHeader http.Header
+	RequestTime   time.Time
+	ResponseTime  time.Time
+}
+
+// CacheStore stores cache entries. Entries are never modified after being stored,
+// so implementations may keep the pointers they receive.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// Cache is an RFC 9111 HTTP cache for GET requests, added to a request pipeline
+// with Middleware. It honors Cache-Control (max-age, s-maxage, no-store, no-cache,
+// private, must-revalidate, stale-while-revalidate and stale-if-error), Expires and
+// Vary, and revalidates stale responses with ETag and Last-Modified conditional
+// requests. Only responses with an acceptable status code are stored.
+//
+//	cache := snowy.NewCache(snowy.NewMemoryCache(64 << 20))
+//	config := snowy.Config{Middleware: []snowy.Middleware{cache.Middleware()}}
+type Cache struct {
+	Store        CacheStore
+	Shared       bool          // Behave as a shared cache: skip private responses and use s-maxage
+	StaleIfError time.Duration // Serve responses stale by up to this long when the server fails or cannot be reached
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+func NewCache(store CacheStore) *Cache {
+	return &Cache{Store: store}
+}
+
+// Middleware returns the middleware that serves requests from the cache.
+func (c *Cache) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				res, err := next(req)
+				if err == nil && !isSafeMethod(req.Method) {
+					c.Store.Delete(cacheKey(req))
+				}
+				return res, err
+			}
+			return c.roundTrip(next, req)
+		}
+	}
+}
+
+func (c *Cache) roundTrip(next RoundTripFunc, req *http.Request) (*http.Response, error) {
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") {
+		return next(req)
+	}
+
+	key, entry := c.lookup(req)
+	if entry == nil {
+		return c.fetch(next, req, nil)
+	}
+
+	now := time.Now()
+	resCC := parseCacheControl(entry.Header)
+	age := entry.age(now)
+	lifetime := entry.freshnessLifetime(resCC, c.Shared)
+	if maxAge, ok := reqCC.seconds("max-age"); ok && maxAge < lifetime {
+		lifetime = maxAge
+	}
+	revalidate := reqCC.has("no-cache") || resCC.has("no-cache")
+	if !revalidate && age < lifetime {
+		return entry.response(req, age), nil
+	}
+
+	mayServeStale := !revalidate && !resCC.has("must-revalidate") && !(c.Shared && resCC.has("proxy-revalidate"))
+	if swr, ok := resCC.seconds("stale-while-revalidate"); ok && mayServeStale && age < lifetime+swr {
+		c.revalidate(next, req, key, entry)
+		return entry.response(req, age), nil
+	}
+
+	res, err := c.fetch(next, conditional(req, entry), entry)
+	if mayServeStale && (res == nil || res.StatusCode >= 500) {
+		sie, _ := resCC.seconds("stale-if-error")
+		if sie = max(sie, c.StaleIfError); sie > 0 && age < lifetime+sie {
+			if res != nil {
+				res.Body.Close()
+			}
+			return entry.response(req, entry.age(time.Now())), nil
+		}
+	}
+	return res, err
+}
+
+// fetch sends the request and stores the response. When a stored entry is being
+// revalidated, a 304 Not Modified response refreshes it and the stored response
+// is returned instead.
+func (c *Cache) fetch(next RoundTripFunc, req *http.Request, entry *CacheEntry) (*http.Response, error) {
+	requestTime := time.Now()
+	res, err := next(req)
+	if res == nil {
+		return res, err
+	}
+	responseTime := time.Now()
+
+	if entry != nil && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		updated := *entry
+		updated.Header = entry.Header.Clone()
+		for name, values := range res.Header {
+			switch name {
+			case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+				continue
+			}
+			updated.Header[name] = values
+		}
+		updated.RequestTime, updated.ResponseTime = requestTime, responseTime
+		c.store(req, &updated)
+		return updated.response(req, updated.age(responseTime)), nil
+	}
+	if err != nil || !c.storable(req, res) {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	c.store(req, &CacheEntry{
+		StatusCode:    res.StatusCode,
+		Header:        res.Header.Clone(),
+		Body:          body,
+		RequestHeader: varyHeaders(req, res.Header),
+		RequestTime:   requestTime,
+		ResponseTime:  responseTime,
+	})
+	return res, nil
+}
+
+// defaultRevalidateTimeout limits background revalidations of requests without a
+// deadline.
+const defaultRevalidateTimeout = 30 * time.Second
+
+// revalidate refreshes a stale entry in the background, at most once at a time.
+func (c *Cache) revalidate(next RoundTripFunc, req *http.Request, key string, entry *CacheEntry) {
+	c.mu.Lock()
+	if c.revalidating[key] {
+		c.mu.Unlock()
+		return
+	}
+	if c.revalidating == nil {
+		c.revalidating = make(map[string]bool)
+	}
+	c.revalidating[key] = true
+	c.mu.Unlock()
+
+	// The background request outlives the attempt that started it, but keeps its
+	// time limit so a server that never answers cannot block revalidation for good.
+	timeout := defaultRevalidateTimeout
+	if deadline, ok := req.Context().Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+	bgReq := conditional(req.WithContext(ctx), entry)
+	go func() {
+		defer func() {
+			cancel()
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+		if res, _ := c.fetch(next, bgReq, entry); res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+	}()
+}
+
+// lookup returns the stored entry matching the request. Responses with a Vary
+// header are stored under a secondary key derived from the varying request
+// headers, while the primary key holds an index entry listing them.
+func (c *Cache) lookup(req *http.Request) (string, *CacheEntry) {
+	key := cacheKey(req)
+	entry, ok := c.Store.Get(key)
+	if !ok {
+		return key, nil
+	}
+	if entry.StatusCode != 0 {
+		return key, entry
+	}
+	variant, ok := variantKey(key, req, entry.Header)
+	if !ok {
+		return key, nil
+	}
+	entry, ok = c.Store.Get(variant)
+	if !ok || !matchesVary(req, entry) {
+		return variant, nil
+	}
+	return variant, entry
+}
+
+func (c *Cache) store(req *http.Request, entry *CacheEntry) {
+	key := cacheKey(req)
+	if len(entry.Header.Values("Vary")) == 0 {
+		c.Store.Set(key, entry)
+		return
+	}
+	variant, ok := variantKey(key, req, entry.Header)
+	if !ok {
+		return
+	}
+	c.Store.Set(key, &CacheEntry{Header: http.Header{"Vary": entry.Header.Values("Vary")}})
+	c.Store.Set(variant, entry)
+}
+
+func (c *Cache) storable(req *http.Request, res *http.Response) bool {
+	reqCC := parseCacheControl(req.Header)
+	resCC := parseCacheControl(res.Header)
+	if reqCC.has("no-store") || resCC.has("no-store") {
+		return false
+	}
+	if c.Shared {
+		if resCC.has("private") {
+			return false
+		}
+		if req.Header.Get("Authorization") != "" && !resCC.has("public") && !resCC.has("s-maxage") && !resCC.has("must-revalidate") {
+			return false
+		}
+	}
+	if slices.Contains(varyNames(res.Header), "*") {
+		return false
+	}
+	if resCC.has("max-age") || (c.Shared && resCC.has("s-maxage")) || res.Header.Get("Expires") != "" || resCC.has("public") || resCC.has("no-cache") {
+		return true
+	}
+	return heuristicallyCacheable(res.StatusCode)
+}
+
+func (e *CacheEntry) response(req *http.Request, age time.Duration) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// age computes the current age of the entry (RFC 9111, section 4.2.3).
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	date := e.ResponseTime
+	if value, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		date = value
+	}
+	apparentAge := max(e.ResponseTime.Sub(date), 0)
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(e.ResponseTime)
+}
+
+// freshnessLifetime computes how long the entry is fresh for (RFC 9111,
+// section 4.2.1), falling back to a heuristic of 10% of the time since the
+// Last-Modified date.
+func (e *CacheEntry) freshnessLifetime(cc cacheControl, shared bool) time.Duration {
+	if shared {
+		if lifetime, ok := cc.seconds("s-maxage"); ok {
+			return lifetime
+		}
+	}
+	if lifetime, ok := cc.seconds("max-age"); ok {
+		return lifetime
+	}
+	date := e.ResponseTime
+	if value, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		date = value
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		value, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return max(value.Sub(date), 0)
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && heuristicallyCacheable(e.StatusCode) {
+		return min(max(date.Sub(lastModified)/10, 0), 24*time.Hour)
+	}
+	return 0
+}
+
+// conditional returns a copy of req asking the server to validate entry, unless
+// the caller already made the request conditional.
+func conditional(req *http.Request, entry *CacheEntry) *http.Request {
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return req
+	}
+	etag := entry.Header.Get("ETag")
+	lastModified := entry.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return req
+}
+
+func cacheKey(req *http.Request) string {
+	return http.MethodGet + " " + req.URL.String()
+}
+
+func variantKey(key string, req *http.Request, header http.Header) (string, bool) {
+	names := varyNames(header)
+	if slices.Contains(names, "*") {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range names {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(req.Header.Values(name), ", "))
+	}
+	return b.String(), true
+}
+
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func varyHeaders(req *http.Request, header http.Header) http.Header {
+	names := varyNames(header)
+	if len(names) == 0 {
+		return nil
+	}
+	values := make(http.Header, len(names))
+	for _, name := range names {
+		values[name] = slices.Clone(req.Header.Values(name))
+	}
+	return values
+}
+
+func matchesVary(req *http.Request, entry *CacheEntry) bool {
+	for _, name := range varyNames(entry.Header) {
+		if strings.Join(req.Header.Values(name), ", ") != strings.Join(entry.RequestHeader.Values(name), ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func heuristicallyCacheable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusPartialContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone, http.StatusRequestURITooLong,
+		http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// cacheControl holds the directives of Cache-Control headers, keyed by their
+// lowercase name.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+				continue
+			}
+			if _, ok := cc[name]; !ok {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// MemoryCache is an in-memory CacheStore that evicts the least recently used
+// entries once the size of the stored responses exceeds its limit.
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List
+	items    map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+	size  int64
+}
+
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(elem)
+	return elem.Value.(*memoryCacheItem).entry, true
+}
+
+func (m *MemoryCache) Set(key string, entry *CacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+	item := &memoryCacheItem{key: key, entry: entry, size: entry.size() + int64(len(key))}
+	if item.size > m.maxBytes {
+		return
+	}
+	m.items[key] = m.lru.PushFront(item)
+	m.size += item.size
+	for m.size > m.maxBytes {
+		m.remove(m.lru.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+}
+
+// Size returns the number of bytes currently stored.
+func (m *MemoryCache) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.size
+}
+
+func (m *MemoryCache) remove(key string) {
+	elem, ok := m.items[key]
+	if !ok {
+		return
+	}
+	m.lru.Remove(elem)
+	delete(m.items, key)
+	m.size -= elem.Value.(*memoryCacheItem).size
+}
+
+// size approximates the memory used by the entry.
+func (e *CacheEntry) size() int64 {
+	size := int64(len(e.Body))
+	for _, header := range []http.Header{e.Header, e.RequestHeader} {
+		for name, values := range header {
+			size += int64(len(name))
+			for _, value := range values {
+				size += int64(len(value))
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,316 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cachedConfig(cache *snowy.Cache) snowy.Config {
+	return snowy.Config{Middleware: []snowy.Middleware{cache.Middleware()}}
+}
+
+func TestSnowyCache(t *testing.T) {
+	t.Run("serves fresh responses from cache", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			json.NewEncoder(w).Encode(TestResponse{Message: "reference data"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		for range 3 {
+			res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+			assert.Nil(t, err)
+			assert.Equal(t, "reference data", res.Data.Message)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("does not store no-store responses", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60, no-store")
+			json.NewEncoder(w).Encode(TestResponse{Message: "secret"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("shared cache skips private responses", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			json.NewEncoder(w).Encode(TestResponse{Message: "mine"})
+		}))
+		defer ts.Close()
+
+		cache := snowy.NewCache(snowy.NewMemoryCache(1 << 20))
+		cache.Shared = true
+		config := cachedConfig(cache)
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("expires header", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			json.NewEncoder(w).Encode(TestResponse{Message: "expires"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("revalidates stale responses", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			json.NewEncoder(w).Encode(TestResponse{Message: "validated"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		for range 2 {
+			res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "validated", res.Data.Message)
+		}
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("revalidates with last modified", func(t *testing.T) {
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("Last-Modified", lastModified)
+			if calls.Add(1) > 1 {
+				assert.Equal(t, lastModified, r.Header.Get("If-Modified-Since"))
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			json.NewEncoder(w).Encode(TestResponse{Message: "modified"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "modified", res.Data.Message)
+	})
+
+	t.Run("varies on request headers", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			json.NewEncoder(w).Encode(TestResponse{Message: r.Header.Get("Accept-Language")})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		for _, language := range []string{"en", "pt", "en", "pt"} {
+			res, err := snowy.Get[TestResponse](config, ts.URL, snowy.Headers{"Accept-Language": language}, snowy.RequestData{})
+			assert.Nil(t, err)
+			assert.Equal(t, language, res.Data.Message)
+		}
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("stale while revalidate", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60, stale-while-revalidate=600")
+			if calls.Add(1) == 1 {
+				w.Header().Set("Age", "120")
+				json.NewEncoder(w).Encode(TestResponse{Message: "stale"})
+				return
+			}
+			json.NewEncoder(w).Encode(TestResponse{Message: "fresh"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "stale", res.Data.Message)
+
+		assert.Eventually(t, func() bool {
+			res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+			return err == nil && res.Data.Message == "fresh"
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("background revalidation is limited by the timeout", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60, stale-while-revalidate=600")
+			switch calls.Add(1) {
+			case 1:
+				w.Header().Set("Age", "120")
+				json.NewEncoder(w).Encode(TestResponse{Message: "stale"})
+			case 2:
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			default:
+				json.NewEncoder(w).Encode(TestResponse{Message: "fresh"})
+			}
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		config.Timeout = 50 * time.Millisecond
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Eventually(t, func() bool {
+			res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+			return err == nil && res.Data.Message == "fresh"
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("stale if error", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=60, stale-if-error=600")
+			w.Header().Set("Age", "120")
+			json.NewEncoder(w).Encode(TestResponse{Message: "stale"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "stale", res.Data.Message)
+	})
+
+	t.Run("must revalidate does not serve stale on error", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate, stale-if-error=600")
+			w.Header().Set("Age", "120")
+			json.NewEncoder(w).Encode(TestResponse{Message: "stale"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, res)
+		assert.IsType(t, &snowy.RequestError{}, err)
+	})
+
+	t.Run("unsafe methods invalidate", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			json.NewEncoder(w).Encode(TestResponse{Message: r.Method})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		snowy.Post[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("request no-cache forces revalidation", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			json.NewEncoder(w).Encode(TestResponse{Message: "data"})
+		}))
+		defer ts.Close()
+
+		config := cachedConfig(snowy.NewCache(snowy.NewMemoryCache(1 << 20)))
+		snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		snowy.Get[TestResponse](config, ts.URL, snowy.Headers{"Cache-Control": "no-cache"}, snowy.RequestData{})
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestSnowyMemoryCache(t *testing.T) {
+	t.Run("evicts least recently used entries", func(t *testing.T) {
+		store := snowy.NewMemoryCache(250)
+		entry := func() *snowy.CacheEntry {
+			return &snowy.CacheEntry{StatusCode: http.StatusOK, Body: make([]byte, 100)}
+		}
+		store.Set("a", entry())
+		store.Set("b", entry())
+		_, ok := store.Get("a")
+		assert.True(t, ok)
+
+		store.Set("c", entry())
+		_, ok = store.Get("b")
+		assert.False(t, ok)
+		_, ok = store.Get("a")
+		assert.True(t, ok)
+		_, ok = store.Get("c")
+		assert.True(t, ok)
+		assert.LessOrEqual(t, store.Size(), int64(250))
+	})
+
+	t.Run("skips entries larger than the limit", func(t *testing.T) {
+		store := snowy.NewMemoryCache(10)
+		store.Set("large", &snowy.CacheEntry{Body: make([]byte, 100)})
+		_, ok := store.Get("large")
+		assert.False(t, ok)
+		assert.Equal(t, int64(0), store.Size())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := snowy.NewMemoryCache(1 << 10)
+		store.Set("key", &snowy.CacheEntry{Body: []byte("value")})
+		store.Delete("key")
+		_, ok := store.Get("key")
+		assert.False(t, ok)
+		assert.Equal(t, int64(0), store.Size())
+	})
+}
@@ -0,0 +1,158 @@
+package snowy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader keeps a client certificate loaded from files that are rotated on
+// disk, such as the ones written by a certificate sidecar. The files are polled
+// and, when their contents change, the new certificate is validated and swapped in
+// for the following TLS handshakes. Invalid certificates, including a key written
+// before its certificate, are ignored and the current certificate is kept.
+//
+//	reloader, err := snowy.NewCertReloader("client.pem", "client-key.pem", time.Minute)
+//	if err != nil {
+//		return err
+//	}
+//	defer reloader.Close()
+//
+//	config := snowy.Config{TLS: &snowy.TLSConfig{CertReloader: reloader}}
+//
+// Established connections keep the certificate they were opened with. A
+// CertReloader built without NewCertReloader loads its certificate on first use
+// and is only reloaded by calling Reload.
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+	OnError  func(err error) // Called when a changed certificate fails to load or validate
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certPEM  []byte
+	keyPEM   []byte
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCertReloader loads the certificate in certFile and keyFile, and polls them
+// for changes every interval, 1 minute by default. It fails when the initial
+// certificate is invalid.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{CertFile: certFile, KeyFile: keyFile, stop: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go r.poll(interval)
+	return r, nil
+}
+
+func (r *CertReloader) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Reload(); err != nil && r.OnError != nil {
+				r.OnError(err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Reload reads the files and swaps in their certificate if they changed and the
+// certificate is valid. On error, the current certificate is kept.
+func (r *CertReloader) Reload() error {
+	certPEM, err := os.ReadFile(r.CertFile)
+	if err != nil {
+		return fmt.Errorf("reloading client certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("reloading client certificate: %w", err)
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("reloading client certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("reloading client certificate: %w", err)
+		}
+	}
+	now := time.Now()
+	if now.Before(cert.Leaf.NotBefore) {
+		return errors.New("reloading client certificate: certificate is not valid yet")
+	}
+	if now.After(cert.Leaf.NotAfter) {
+		return errors.New("reloading client certificate: certificate has expired")
+	}
+
+	r.mu.Lock()
+	r.cert, r.certPEM, r.keyPEM = &cert, certPEM, keyPEM
+	r.mu.Unlock()
+	return nil
+}
+
+// Certificate returns the current certificate, nil until one has been loaded.
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// current returns the current certificate, loading it first when a CertReloader
+// built without NewCertReloader has none yet.
+func (r *CertReloader) current() (*tls.Certificate, error) {
+	if cert := r.Certificate(); cert != nil {
+		return cert, nil
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate returns the current certificate, for use as
+// tls.Config.GetClientCertificate.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.current()
+}
+
+// Expiry returns when the current certificate expires, or an error when no valid
+// certificate could be loaded.
+func (r *CertReloader) Expiry() (time.Time, error) {
+	cert, err := r.current()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.Leaf.NotAfter, nil
+}
+
+// Close stops polling the files.
+func (r *CertReloader) Close() {
+	r.stopOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package snowy_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	certFile, keyFile, first := writeClientCertificate(t, dir, "first", expiry)
+
+	// Rotated certificates are generated elsewhere and copied over the originals.
+	rotatedDir := t.TempDir()
+	rotatedCert, rotatedKey, second := writeClientCertificate(t, rotatedDir, "second", expiry.Add(time.Hour))
+	expiryOf := func(reloader *snowy.CertReloader) time.Time {
+		t.Helper()
+		expiry, err := reloader.Expiry()
+		assert.Nil(t, err)
+		return expiry
+	}
+	rotate := func(certFile, keyFile string) {
+		t.Helper()
+		for src, dst := range map[string]string{rotatedCert: certFile, rotatedKey: keyFile} {
+			data, err := os.ReadFile(src)
+			assert.Nil(t, err)
+			assert.Nil(t, os.WriteFile(dst, data, 0o600))
+		}
+	}
+
+	t.Run("swaps rotated certificates", func(t *testing.T) {
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(first)
+		clientCAs.AddCert(second)
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Every request opens a new connection, and so a new handshake.
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+		}))
+		ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		ts.StartTLS()
+		defer ts.Close()
+		roots := x509.NewCertPool()
+		roots.AddCert(ts.Certificate())
+
+		reloader, err := snowy.NewCertReloader(certFile, keyFile, 10*time.Millisecond)
+		assert.Nil(t, err)
+		defer reloader.Close()
+		assert.Equal(t, expiry, expiryOf(reloader))
+
+		config := snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots, CertReloader: reloader}}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "first", res.Data.Message)
+
+		rotate(certFile, keyFile)
+		assert.Eventually(t, func() bool { return expiryOf(reloader).Equal(expiry.Add(time.Hour)) }, time.Second, 10*time.Millisecond)
+
+		res, err = snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "second", res.Data.Message)
+	})
+
+	t.Run("keeps the current certificate when the new one is invalid", func(t *testing.T) {
+		certFile, keyFile, _ := writeClientCertificate(t, t.TempDir(), "first", expiry)
+		reloader, err := snowy.NewCertReloader(certFile, keyFile, time.Hour)
+		assert.Nil(t, err)
+		defer reloader.Close()
+
+		// Only the certificate was written so far, it does not match the key.
+		data, err := os.ReadFile(rotatedCert)
+		assert.Nil(t, err)
+		assert.Nil(t, os.WriteFile(certFile, data, 0o600))
+		assert.NotNil(t, reloader.Reload())
+		assert.Equal(t, expiry, expiryOf(reloader))
+
+		assert.Nil(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+		assert.NotNil(t, reloader.Reload())
+		assert.Equal(t, expiry, expiryOf(reloader))
+
+		rotate(certFile, keyFile)
+		assert.Nil(t, reloader.Reload())
+		assert.Equal(t, expiry.Add(time.Hour), expiryOf(reloader))
+	})
+
+	t.Run("built without NewCertReloader", func(t *testing.T) {
+		reloader := &snowy.CertReloader{CertFile: certFile + ".missing", KeyFile: keyFile}
+		notAfter, err := reloader.Expiry()
+		assert.ErrorContains(t, err, "reloading client certificate")
+		assert.True(t, notAfter.IsZero())
+		cert, err := reloader.GetClientCertificate(nil)
+		assert.Nil(t, cert)
+		assert.NotNil(t, err)
+
+		certFile, keyFile, _ := writeClientCertificate(t, t.TempDir(), "lazy", expiry.Add(time.Hour))
+		reloader = &snowy.CertReloader{CertFile: certFile, KeyFile: keyFile}
+		cert, err = reloader.GetClientCertificate(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "lazy", cert.Leaf.Subject.CommonName)
+		reloader.Close()
+	})
+
+	t.Run("rejects expired certificates", func(t *testing.T) {
+		certFile, keyFile, _ := writeClientCertificate(t, t.TempDir(), "expired", time.Now().Add(-time.Hour))
+		_, err := snowy.NewCertReloader(certFile, keyFile, time.Hour)
+		assert.ErrorContains(t, err, "expired")
+	})
+}
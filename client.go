@@ -0,0 +1,154 @@
+package snowy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client holds the settings shared by every request made against a single API:
+// the base URL, default headers, user agent and Config. A Client is safe for
+// concurrent use as long as it is not modified after the first request.
+//
+// Go does not allow type parameters on methods, so requests are made through the
+// ClientGet, ClientPost, ClientPut, ClientPatch and ClientDelete functions, or
+// their Ctx variants taking a context:
+//
+//	api := snowy.NewClient("https://api.example.com/v1", snowy.Config{Timeout: 5 * time.Second})
+//	api.Headers.AddBearer("your-token-here")
+//
+//	response, err := snowy.ClientGet[UserResponse](api, "users/1", nil, snowy.RequestData{})
+type Client struct {
+	BaseURL   string
+	Headers   Headers
+	Config    Config
+	UserAgent string
+}
+
+func NewClient(baseURL string, config Config) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		Headers: Headers{},
+		Config:  config,
+	}
+}
+
+// resolve returns the absolute URL for path, after expanding it as a URI template
+// when params are given. Absolute http and https URLs are returned as is, anything
+// else is resolved relative to BaseURL, which is treated as a directory so "users",
+// "/users" and "projects:list" all end up below a base such as "https://host/v1".
+func (c *Client) resolve(path string, params any) (string, error) {
+	if params != nil {
+		expanded, err := ExpandURI(path, params)
+		if err != nil {
+			return "", err
+		}
+		path = expanded
+	}
+	if ref, err := url.Parse(path); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") && ref.Host != "" {
+		return path, nil
+	}
+	if c.BaseURL == "" {
+		return path, nil
+	}
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing base URL: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+		if base.RawPath != "" {
+			base.RawPath += "/"
+		}
+	}
+	// The "./" prefix keeps a path such as "//users" from being parsed as a
+	// scheme-relative URL, and "projects:list" as a URL with a scheme.
+	ref, err := url.Parse("./" + strings.TrimLeft(path, "/"))
+	if err != nil {
+		return "", fmt.Errorf("parsing request path: %w", err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// headers merges the per-call headers over the client defaults. The result is
+// always a new map, so neither the client nor the caller's headers are modified.
+func (c *Client) headers(headers map[string]string) map[string]string {
+	merged := make(map[string]string, len(c.Headers)+len(headers)+1)
+	if c.UserAgent != "" {
+		merged["User-Agent"] = c.UserAgent
+	}
+	for k, v := range c.Headers {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range headers {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	return merged
+}
+
+func ClientGet[T any](c *Client, path string, headers map[string]string, query RequestData) (*Response[T], error) {
+	return ClientGetCtx[T](c.Config.context(), c, path, headers, query)
+}
+
+func ClientPost[T any](c *Client, path string, headers map[string]string, body RequestData) (*Response[T], error) {
+	return ClientPostCtx[T](c.Config.context(), c, path, headers, body)
+}
+
+func ClientPut[T any](c *Client, path string, headers map[string]string, body RequestData) (*Response[T], error) {
+	return ClientPutCtx[T](c.Config.context(), c, path, headers, body)
+}
+
+func ClientPatch[T any](c *Client, path string, headers map[string]string, body RequestData) (*Response[T], error) {
+	return ClientPatchCtx[T](c.Config.context(), c, path, headers, body)
+}
+
+func ClientDelete[T any](c *Client, path string, headers map[string]string, query RequestData) (*Response[T], error) {
+	return ClientDeleteCtx[T](c.Config.context(), c, path, headers, query)
+}
+
+func ClientGetCtx[T any](ctx context.Context, c *Client, path string, headers map[string]string, query RequestData) (*Response[T], error) {
+	url, err := c.resolve(path, query.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	query.PathParams = nil
+	return GetCtx[T](ctx, c.Config, url, c.headers(headers), query)
+}
+
+func ClientPostCtx[T any](ctx context.Context, c *Client, path string, headers map[string]string, body RequestData) (*Response[T], error) {
+	url, err := c.resolve(path, body.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	body.PathParams = nil
+	return PostCtx[T](ctx, c.Config, url, c.headers(headers), body)
+}
+
+func ClientPutCtx[T any](ctx context.Context, c *Client, path string, headers map[string]string, body RequestData) (*Response[T], error) {
+	url, err := c.resolve(path, body.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	body.PathParams = nil
+	return PutCtx[T](ctx, c.Config, url, c.headers(headers), body)
+}
+
+func ClientPatchCtx[T any](ctx context.Context, c *Client, path string, headers map[string]string, body RequestData) (*Response[T], error) {
+	url, err := c.resolve(path, body.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	body.PathParams = nil
+	return PatchCtx[T](ctx, c.Config, url, c.headers(headers), body)
+}
+
+func ClientDeleteCtx[T any](ctx context.Context, c *Client, path string, headers map[string]string, query RequestData) (*Response[T], error) {
+	url, err := c.resolve(path, query.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	query.PathParams = nil
+	return DeleteCtx[T](ctx, c.Config, url, c.headers(headers), query)
+}
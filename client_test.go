@@ -0,0 +1,119 @@
+package snowy_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyClient(t *testing.T) {
+	t.Run("resolves paths against base url", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/users/123", r.URL.Path)
+			assert.Equal(t, "search=test", r.URL.RawQuery)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(TestResponse{
+				User:    &FakeUser{ID: "123"},
+				Message: "success",
+			})
+		}))
+		defer ts.Close()
+
+		client := snowy.NewClient(ts.URL+"/v1", snowy.Config{})
+		for _, path := range []string{"users/123", "/users/123", "//users/123"} {
+			res, err := snowy.ClientGet[TestResponse](client, path, nil, snowy.RequestData{
+				QueryParams: map[string]string{"search": "test"},
+			})
+			assert.Nil(t, err)
+			assert.NotNil(t, res)
+			assert.Equal(t, "123", res.Data.User.ID)
+		}
+	})
+
+	t.Run("resolves paths with colons against base url", func(t *testing.T) {
+		var paths []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		client := snowy.NewClient(ts.URL+"/v1", snowy.Config{})
+		for _, path := range []string{"projects:list", "/users:batchGet", "users/1:cancel"} {
+			_, err := snowy.ClientPost[TestResponse](client, path, nil, snowy.RequestData{})
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, []string{"/v1/projects:list", "/v1/users:batchGet", "/v1/users/1:cancel"}, paths)
+	})
+
+	t.Run("context variants", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/users/123", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		client := snowy.NewClient(ts.URL+"/v1", snowy.Config{})
+		res, err := snowy.ClientPutCtx[TestResponse](context.Background(), client, "users/123", nil, snowy.RequestData{JsonData: FakeUser{ID: "123"}})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = snowy.ClientGetCtx[TestResponse](ctx, client, "users/123", nil, snowy.RequestData{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("absolute url bypasses base url", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/other", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		client := snowy.NewClient("http://invalid.example", snowy.Config{})
+		res, err := snowy.ClientDelete[TestResponse](client, ts.URL+"/other", nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("merges headers over client defaults", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "Bearer override", r.Header.Get("Authorization"))
+			assert.Equal(t, "default", r.Header.Get("X-Default"))
+			assert.Equal(t, "snowy-test/1.0", r.Header.Get("User-Agent"))
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		client := snowy.NewClient(ts.URL, snowy.Config{})
+		client.UserAgent = "snowy-test/1.0"
+		client.Headers.AddBearer("default")
+		client.Headers.Add("X-Default", "default")
+
+		headers := snowy.Headers{"authorization": "Bearer override"}
+		res, err := snowy.ClientPost[TestResponse](client, "/users", headers, snowy.RequestData{
+			JsonData: FakeUser{ID: "123"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Bearer default", client.Headers.Get("Authorization"))
+		assert.NotContains(t, headers, "Content-Type")
+	})
+
+	t.Run("invalid base url", func(t *testing.T) {
+		client := snowy.NewClient("://invalid", snowy.Config{})
+		res, err := snowy.ClientPut[TestResponse](client, "users", nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+		assert.Nil(t, res)
+	})
+}
@@ -0,0 +1,179 @@
+package snowy
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCachedClients is the number of clients kept in the cache. Beyond it, the least
+// recently used client is evicted and its idle connections are closed.
+const maxCachedClients = 64
+
+// clientKey holds every Config field that affects the client or its transport.
+// Configurations with equal keys share connections.
+type clientKey struct {
+	maxIdleConns        int
+	idleConnTimeout     time.Duration
+	tlsHandshakeTimeout time.Duration
+	tls                 string
+	proxy               string
+}
+
+func (c Config) clientKey() clientKey {
+	return clientKey{
+		maxIdleConns:        c.MaxIdleConns,
+		idleConnTimeout:     c.IdleConnTimeout,
+		tlsHandshakeTimeout: c.TLSHandshakeTimeout,
+		tls:                 c.TLS.key(),
+		proxy:               c.proxy().key(),
+	}
+}
+
+func (c Config) proxy() *ProxyConfig {
+	if c.Proxy == nil {
+		return ProxyFromEnvironment()
+	}
+	return c.Proxy
+}
+
+type cachedClient struct {
+	client  *http.Client
+	element *list.Element
+	refs    int  // Requests using the client
+	evicted bool // Removed from the cache, closed once refs drops to zero
+	closed  chan struct{}
+}
+
+var clients = struct {
+	mu    sync.Mutex
+	byKey map[clientKey]*cachedClient
+	lru   list.List // Of clientKey, the most recently used first
+}{byKey: make(map[clientKey]*cachedClient)}
+
+// getClient returns the cached client for config, creating it when needed. The
+// release function must be called once the response is no longer used.
+// Configurations with their own client or transport bypass the cache.
+func getClient(config Config) (*http.Client, func(), error) {
+	if config.HTTPClient != nil {
+		return config.HTTPClient, func() {}, nil
+	}
+	if config.Transport != nil {
+		return &http.Client{Transport: config.Transport}, func() {}, nil
+	}
+
+	key := config.clientKey()
+	clients.mu.Lock()
+	c, ok := clients.byKey[key]
+	if ok {
+		c.refs++
+		clients.lru.MoveToFront(c.element)
+	}
+	clients.mu.Unlock()
+	if ok {
+		return c.client, sync.OnceFunc(c.release), nil
+	}
+
+	client, err := newClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	// Another request may have created the client meanwhile. The new one has no
+	// connections yet and is dropped.
+	if c, ok := clients.byKey[key]; ok {
+		c.refs++
+		clients.lru.MoveToFront(c.element)
+		return c.client, sync.OnceFunc(c.release), nil
+	}
+	c = &cachedClient{client: client, refs: 1, closed: make(chan struct{})}
+	c.element = clients.lru.PushFront(key)
+	clients.byKey[key] = c
+	for clients.lru.Len() > maxCachedClients {
+		evictClient(clients.lru.Back().Value.(clientKey))
+	}
+	return c.client, sync.OnceFunc(c.release), nil
+}
+
+func newClient(config Config) (*http.Client, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        config.MaxIdleConns,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+		// A custom TLSClientConfig otherwise disables HTTP/2.
+		ForceAttemptHTTP2: true,
+	}
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.clientConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	proxy, err := config.proxy().proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// evictClient removes the client from the cache, closing it unless it is in use.
+// It must be called with clients.mu held.
+func evictClient(key clientKey) *cachedClient {
+	c := clients.byKey[key]
+	delete(clients.byKey, key)
+	clients.lru.Remove(c.element)
+	c.evicted = true
+	if c.refs == 0 {
+		c.close()
+	}
+	return c
+}
+
+func (c *cachedClient) release() {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	c.refs--
+	if c.evicted && c.refs == 0 {
+		c.close()
+	}
+}
+
+func (c *cachedClient) close() {
+	c.client.CloseIdleConnections()
+	close(c.closed)
+}
+
+// Shutdown removes every cached client and closes their connections, waiting for
+// the requests in progress to complete. If ctx ends first, Shutdown returns its
+// error, and the remaining connections are closed as their requests complete.
+// Requests made after Shutdown create new clients.
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	if err := snowy.Shutdown(ctx); err != nil {
+//		log.Printf("closing connections: %v", err)
+//	}
+func Shutdown(ctx context.Context) error {
+	clients.mu.Lock()
+	var drained []*cachedClient
+	for clients.lru.Len() > 0 {
+		drained = append(drained, evictClient(clients.lru.Front().Value.(clientKey)))
+	}
+	clients.mu.Unlock()
+
+	for _, c := range drained {
+		select {
+		case <-c.closed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package snowy_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTransport stands in for an instrumented transport.
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Instrumented", "true")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSnowyCustomClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("X-Instrumented"))
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer ts.Close()
+
+	t.Run("transport", func(t *testing.T) {
+		transport := &countingTransport{}
+		config := snowy.Config{Transport: transport}
+		res, err := snowy.Post[TestResponse](config, ts.URL, nil, snowy.RequestData{JsonData: FakeUser{ID: "1"}})
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", res.Data.Message)
+
+		_, err = snowy.Get[TestResponse](config, ts.URL+"/missing", nil, snowy.RequestData{})
+		var reqErr *snowy.RequestError
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, http.StatusNotFound, reqErr.StatusCode)
+		assert.Equal(t, int32(2), transport.calls.Load())
+	})
+
+	t.Run("http client", func(t *testing.T) {
+		transport := &countingTransport{}
+		// The client takes precedence over the transport.
+		config := snowy.Config{HTTPClient: &http.Client{Transport: transport}, Transport: http.DefaultTransport}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", res.Data.Message)
+		assert.Equal(t, int32(1), transport.calls.Load())
+	})
+
+	t.Run("streams ignore the client timeout", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for i := range 3 {
+				w.Write([]byte(`{"message":"` + string(rune('a'+i)) + `"}` + "\n"))
+				w.(http.Flusher).Flush()
+				time.Sleep(30 * time.Millisecond)
+			}
+		}))
+		defer slow.Close()
+
+		transport := &countingTransport{}
+		config := snowy.Config{HTTPClient: &http.Client{Transport: transport, Timeout: 20 * time.Millisecond}}
+		var messages []string
+		for item, err := range snowy.Stream[TestResponse](config, http.MethodGet, slow.URL, nil, snowy.RequestData{}) {
+			assert.Nil(t, err)
+			messages = append(messages, item.Message)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, messages)
+		assert.Equal(t, int32(1), transport.calls.Load())
+	})
+}
+
+func TestSnowyClientCache(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"ok"}`))
+	}
+
+	t.Run("configurations share clients by transport settings", func(t *testing.T) {
+		var opened atomic.Int32
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(ok))
+		ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				opened.Add(1)
+			}
+		}
+		ts.Start()
+		defer ts.Close()
+
+		for _, config := range []snowy.Config{
+			{MaxIdleConns: 10},
+			{MaxIdleConns: 10, AcceptableStatusCodes: []int{202}, Timeout: time.Minute},
+			{MaxIdleConns: 11},
+			{MaxIdleConns: 10, IdleConnTimeout: time.Minute + time.Microsecond},
+			{MaxIdleConns: 10, Proxy: &snowy.ProxyConfig{NoProxy: "*"}},
+		} {
+			_, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, int32(4), opened.Load())
+	})
+
+	t.Run("evicts least recently used clients", func(t *testing.T) {
+		var closed atomic.Int32
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(ok))
+		ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				closed.Add(1)
+			}
+		}
+		ts.Start()
+		defer ts.Close()
+		other := httptest.NewServer(http.HandlerFunc(ok))
+		defer other.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{IdleConnTimeout: time.Hour}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		for i := range 100 {
+			_, err := snowy.Get[TestResponse](snowy.Config{IdleConnTimeout: time.Minute + time.Duration(i)}, other.URL, nil, snowy.RequestData{})
+			assert.Nil(t, err)
+		}
+		assert.Eventually(t, func() bool { return closed.Load() == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("shutdown closes idle connections", func(t *testing.T) {
+		var opened, closed atomic.Int32
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(ok))
+		ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				opened.Add(1)
+			case http.StateClosed:
+				closed.Add(1)
+			}
+		}
+		ts.Start()
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Nil(t, snowy.Shutdown(context.Background()))
+		assert.Eventually(t, func() bool { return closed.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+		// Requests after shutdown open new connections.
+		_, err = snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), opened.Load())
+	})
+
+	t.Run("shutdown waits for requests in progress", func(t *testing.T) {
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		var closed atomic.Int32
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-unblock
+			ok(w, r)
+		}))
+		ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				closed.Add(1)
+			}
+		}
+		ts.Start()
+		defer ts.Close()
+
+		done := make(chan error)
+		go func() {
+			_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+			done <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, snowy.Shutdown(ctx), context.DeadlineExceeded)
+		assert.Equal(t, int32(0), closed.Load())
+
+		close(unblock)
+		assert.Nil(t, <-done)
+		assert.Eventually(t, func() bool { return closed.Load() == 1 }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,267 @@
+package snowy
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestAuth authenticates requests with HTTP Digest access authentication
+// (RFC 7616), supporting the MD5, SHA-256 and their -sess algorithms. The first
+// request is answered with a 401 challenge, after which it is replayed with the
+// computed credentials. The challenge is cached and reused, with an incremented
+// nonce count, by the following requests until the server sends a new one.
+//
+//	config := snowy.Config{Middleware: []snowy.Middleware{snowy.DigestAuth("admin", "secret")}}
+//
+// Requests whose body cannot be replayed, such as multipart forms, fail with the
+// 401 response unless a challenge was already cached.
+func DigestAuth(username, password string) Middleware {
+	d := &digestAuth{username: username, password: password}
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			challenge, err := d.authorize(req)
+			if err != nil {
+				return nil, err
+			}
+			res, err := next(req)
+			if res == nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+
+			fresh, ok := parseDigestChallenge(res.Header.Values("WWW-Authenticate"))
+			// The same nonce being rejected again means the credentials are wrong.
+			if !ok || (challenge != nil && challenge.nonce == fresh.nonce && !fresh.stale) {
+				return res, err
+			}
+			replay, ok := replayRequest(req)
+			if !ok {
+				return res, err
+			}
+			d.mu.Lock()
+			d.challenge, d.count = fresh, 0
+			d.mu.Unlock()
+			if _, err := d.authorize(replay); err != nil {
+				return res, err
+			}
+			res.Body.Close()
+			return next(replay)
+		}
+	}
+}
+
+type digestAuth struct {
+	username string
+	password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	count     uint32
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // Selected quality of protection, empty for RFC 2069 servers
+	stale     bool
+}
+
+// authorize sets the Authorization header from the cached challenge, and returns
+// the challenge used.
+func (d *digestAuth) authorize(req *http.Request) (*digestChallenge, error) {
+	d.mu.Lock()
+	challenge := d.challenge
+	d.count++
+	count := d.count
+	d.mu.Unlock()
+	if challenge == nil {
+		return nil, nil
+	}
+
+	name, session := splitDigestAlgorithm(challenge.algorithm)
+	newHash, ok := digestHashes[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %q", challenge.algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	nc := fmt.Sprintf("%08x", count)
+	cnonceBytes := make([]byte, 16)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+	uri := req.URL.RequestURI()
+
+	a1 := d.username + ":" + challenge.realm + ":" + d.password
+	if session {
+		a1 = h(a1) + ":" + challenge.nonce + ":" + cnonce
+	}
+	a2 := req.Method + ":" + uri
+	if challenge.qop == "auth-int" {
+		body := newHash()
+		if err := copyBody(body, req); err != nil {
+			return nil, fmt.Errorf("reading body to authenticate: %w", err)
+		}
+		a2 += ":" + hex.EncodeToString(body.Sum(nil))
+	}
+	var response string
+	if challenge.qop == "" {
+		response = h(h(a1) + ":" + challenge.nonce + ":" + h(a2))
+	} else {
+		response = h(strings.Join([]string{h(a1), challenge.nonce, nc, cnonce, challenge.qop, h(a2)}, ":"))
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", d.username),
+		fmt.Sprintf("realm=%q", challenge.realm),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + challenge.algorithm,
+		fmt.Sprintf("nonce=%q", challenge.nonce),
+	}
+	if challenge.qop != "" {
+		params = append(params, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce), "qop="+challenge.qop)
+	}
+	params = append(params, fmt.Sprintf("response=%q", response))
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", challenge.opaque))
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+	return challenge, nil
+}
+
+var digestHashes = map[string]func() hash.Hash{
+	"MD5":     md5.New,
+	"SHA-256": sha256.New,
+}
+
+// parseDigestChallenge returns the strongest supported Digest challenge among the
+// WWW-Authenticate header values.
+func parseDigestChallenge(values []string) (*digestChallenge, bool) {
+	var best *digestChallenge
+	for _, challenge := range parseChallenges(values) {
+		if !strings.EqualFold(challenge.scheme, "Digest") {
+			continue
+		}
+		params := challenge.params
+		algorithm := params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		name, _ := splitDigestAlgorithm(algorithm)
+		if _, ok := digestHashes[name]; !ok {
+			continue
+		}
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: algorithm,
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		if qop, ok := params["qop"]; ok {
+			options := strings.Split(qop, ",")
+			for i := range options {
+				options[i] = strings.TrimSpace(options[i])
+			}
+			switch {
+			case containsFold(options, "auth"):
+				c.qop = "auth"
+			case containsFold(options, "auth-int"):
+				c.qop = "auth-int"
+			default:
+				continue
+			}
+		}
+		if best == nil || name == "SHA-256" && !strings.HasPrefix(strings.ToUpper(best.algorithm), "SHA-256") {
+			best = c
+		}
+	}
+	return best, best != nil
+}
+
+// splitDigestAlgorithm returns the uppercase hash name of algorithm, and whether
+// it is a session variant such as MD5-sess.
+func splitDigestAlgorithm(algorithm string) (string, bool) {
+	return strings.CutSuffix(strings.ToUpper(algorithm), "-SESS")
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses WWW-Authenticate header values, each of which may hold
+// several comma separated challenges. Parameter names are lowercased.
+func parseChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, value := range values {
+		var params map[string]string
+		i := 0
+		for i < len(value) {
+			for i < len(value) && (value[i] == ' ' || value[i] == '\t' || value[i] == ',') {
+				i++
+			}
+			start := i
+			for i < len(value) && value[i] != ' ' && value[i] != '\t' && value[i] != ',' && value[i] != '=' {
+				i++
+			}
+			token := value[start:i]
+			if token == "" {
+				break
+			}
+			for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+				i++
+			}
+			if i >= len(value) || value[i] != '=' || params == nil {
+				// A token not followed by "=" starts a new challenge.
+				params = make(map[string]string)
+				challenges = append(challenges, authChallenge{scheme: token, params: params})
+				continue
+			}
+			i++
+			for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+				i++
+			}
+			var param string
+			if i < len(value) && value[i] == '"' {
+				var b strings.Builder
+				for i++; i < len(value) && value[i] != '"'; i++ {
+					if value[i] == '\\' && i+1 < len(value) {
+						i++
+					}
+					b.WriteByte(value[i])
+				}
+				i++
+				param = b.String()
+			} else {
+				start := i
+				for i < len(value) && value[i] != ',' {
+					i++
+				}
+				param = strings.TrimSpace(value[start:i])
+			}
+			params[strings.ToLower(token)] = param
+		}
+	}
+	return challenges
+}
@@ -0,0 +1,147 @@
+package snowy_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Values from the RFC 7616 section 3.9.1 example.
+const (
+	digestRealm  = "http-auth@example.org"
+	digestNonce  = "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+	digestOpaque = "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"
+)
+
+var digestParam = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+func parseDigestAuthorization(value string) map[string]string {
+	params := make(map[string]string)
+	for _, match := range digestParam.FindAllStringSubmatch(strings.TrimPrefix(value, "Digest "), -1) {
+		params[match[1]] = match[2] + match[3]
+	}
+	return params
+}
+
+// digestResponse computes the expected response for qop=auth or qop=auth-int.
+func digestResponse(algorithm, username, password, method string, params map[string]string, body []byte) string {
+	newHash := md5.New
+	if strings.HasPrefix(algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+	a1 := username + ":" + params["realm"] + ":" + password
+	if strings.HasSuffix(algorithm, "-sess") {
+		a1 = h(a1) + ":" + params["nonce"] + ":" + params["cnonce"]
+	}
+	a2 := method + ":" + params["uri"]
+	if params["qop"] == "auth-int" {
+		sum := newHash()
+		sum.Write(body)
+		a2 += ":" + hex.EncodeToString(sum.Sum(nil))
+	}
+	return h(strings.Join([]string{h(a1), params["nonce"], params["nc"], params["cnonce"], params["qop"], h(a2)}, ":"))
+}
+
+func TestSnowyDigestAuth(t *testing.T) {
+	t.Run("rfc 7616 test vectors", func(t *testing.T) {
+		params := map[string]string{
+			"realm": digestRealm, "nonce": digestNonce, "uri": "/dir/index.html",
+			"nc": "00000001", "cnonce": "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "qop": "auth",
+		}
+		assert.Equal(t, "8ca523f5e9506fed4657c9700eebdbec", digestResponse("MD5", "Mufasa", "Circle of Life", "GET", params, nil))
+		assert.Equal(t, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", digestResponse("SHA-256", "Mufasa", "Circle of Life", "GET", params, nil))
+	})
+
+	t.Run("answers challenge and reuses nonce", func(t *testing.T) {
+		var counts []string
+		var unauthorized atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := parseDigestAuthorization(r.Header.Get("Authorization"))
+			if params["response"] == "" || params["response"] != digestResponse(params["algorithm"], "Mufasa", "Circle of Life", r.Method, params, nil) {
+				unauthorized.Add(1)
+				w.Header().Add("WWW-Authenticate", `Digest realm="`+digestRealm+`", qop="auth, auth-int", algorithm=MD5, nonce="`+digestNonce+`", opaque="`+digestOpaque+`"`)
+				w.Header().Add("WWW-Authenticate", `Digest realm="`+digestRealm+`", qop="auth, auth-int", algorithm=SHA-256, nonce="`+digestNonce+`", opaque="`+digestOpaque+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, r.URL.RequestURI(), params["uri"])
+			assert.Equal(t, digestOpaque, params["opaque"])
+			assert.Equal(t, "SHA-256", params["algorithm"])
+			counts = append(counts, params["nc"])
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Middleware: []snowy.Middleware{snowy.DigestAuth("Mufasa", "Circle of Life")}}
+		for range 3 {
+			res, err := snowy.Get[TestResponse](config, ts.URL+"/dir/index.html?page=1", nil, snowy.RequestData{})
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
+		assert.Equal(t, int32(1), unauthorized.Load())
+		assert.Equal(t, []string{"00000001", "00000002", "00000003"}, counts)
+	})
+
+	t.Run("session algorithm with body integrity", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			params := parseDigestAuthorization(r.Header.Get("Authorization"))
+			if params["response"] == "" || params["response"] != digestResponse(params["algorithm"], "Mufasa", "Circle of Life", r.Method, params, body) {
+				w.Header().Set("WWW-Authenticate", `Digest realm="`+digestRealm+`", qop="auth-int", algorithm=MD5-sess, nonce="`+digestNonce+`", opaque="`+digestOpaque+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "auth-int", params["qop"])
+			assert.JSONEq(t, `{"id":"1","username":"","email":""}`, string(body))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Middleware: []snowy.Middleware{snowy.DigestAuth("Mufasa", "Circle of Life")}}
+		res, err := snowy.Post[TestResponse](config, ts.URL+"/dir/index.html", nil, snowy.RequestData{JsonData: FakeUser{ID: "1"}})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		var unauthorized atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := parseDigestAuthorization(r.Header.Get("Authorization"))
+			if params["response"] == "" || params["response"] != digestResponse(params["algorithm"], "Mufasa", "Circle of Life", r.Method, params, nil) {
+				unauthorized.Add(1)
+				w.Header().Set("WWW-Authenticate", `Digest realm="`+digestRealm+`", qop="auth", algorithm=SHA-256, nonce="`+digestNonce+`", opaque="`+digestOpaque+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Middleware: []snowy.Middleware{snowy.DigestAuth("Mufasa", "wrong")}}
+		_, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		var reqErr *snowy.RequestError
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, http.StatusUnauthorized, reqErr.StatusCode)
+		assert.Equal(t, int32(2), unauthorized.Load())
+
+		_, err = snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, int32(3), unauthorized.Load())
+	})
+}
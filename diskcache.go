@@ -0,0 +1,337 @@
+package snowy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DiskCache is a CacheStore that persists responses in a directory, so they can be
+// shared between short-lived processes. Response bodies are stored as files named
+// after their SHA-256 digest, and an index file maps keys to them. Every operation
+// holds a file lock, making the store safe to use from concurrent processes.
+//
+//	dir, _ := os.UserCacheDir()
+//	store, err := snowy.NewDiskCache(filepath.Join(dir, "my-cli"), 100<<20)
+//	if err != nil {
+//		return err
+//	}
+//	cache := snowy.NewCache(store)
+//	cache.StaleIfError = 7 * 24 * time.Hour // Keep working offline for a week
+//
+// CacheStore methods cannot return errors, so a failure to read or write the
+// directory behaves as a cache miss.
+type DiskCache struct {
+	MaxBytes int64         // Size limit of the stored bodies, least recently used entries are evicted first; zero means no limit
+	TTL      time.Duration // Entries stored longer ago are removed, zero keeps them until evicted
+
+	dir string
+	mu  sync.Mutex
+}
+
+// diskEntry is the index record of a stored response.
+type diskEntry struct {
+	Blob          string      `json:"blob"`
+	Size          int64       `json:"size"`
+	StatusCode    int         `json:"status_code"`
+	Header        http.Header `json:"header"`
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	RequestTime   time.Time   `json:"request_time"`
+	ResponseTime  time.Time   `json:"response_time"`
+	StoredAt      time.Time   `json:"stored_at"`
+	AccessedAt    time.Time   `json:"accessed_at"`
+}
+
+type diskIndex map[string]*diskEntry
+
+// NewDiskCache creates a store in dir, creating the directory if needed.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs"), 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir, MaxBytes: maxBytes}, nil
+}
+
+func (d *DiskCache) Get(key string) (*CacheEntry, bool) {
+	var entry *CacheEntry
+	d.update(func(index diskIndex) bool {
+		record, ok := index[key]
+		if !ok {
+			return false
+		}
+		if d.expired(record, time.Now()) {
+			delete(index, key)
+			return true
+		}
+		body, err := os.ReadFile(d.blobPath(record.Blob))
+		if err != nil || blobName(body) != record.Blob {
+			delete(index, key)
+			return true
+		}
+		entry = &CacheEntry{
+			StatusCode:    record.StatusCode,
+			Header:        record.Header,
+			Body:          body,
+			RequestHeader: record.RequestHeader,
+			RequestTime:   record.RequestTime,
+			ResponseTime:  record.ResponseTime,
+		}
+		record.AccessedAt = time.Now()
+		return true
+	})
+	return entry, entry != nil
+}
+
+func (d *DiskCache) Set(key string, entry *CacheEntry) {
+	d.update(func(index diskIndex) bool {
+		blob := blobName(entry.Body)
+		if err := d.writeBlob(blob, entry.Body); err != nil {
+			return false
+		}
+		now := time.Now()
+		index[key] = &diskEntry{
+			Blob:          blob,
+			Size:          int64(len(entry.Body)),
+			StatusCode:    entry.StatusCode,
+			Header:        entry.Header,
+			RequestHeader: entry.RequestHeader,
+			RequestTime:   entry.RequestTime,
+			ResponseTime:  entry.ResponseTime,
+			StoredAt:      now,
+			AccessedAt:    now,
+		}
+		d.evict(index)
+		return true
+	})
+}
+
+func (d *DiskCache) Delete(key string) {
+	d.update(func(index diskIndex) bool {
+		if _, ok := index[key]; !ok {
+			return false
+		}
+		delete(index, key)
+		return true
+	})
+}
+
+// Sweep removes entries older than TTL, enforces MaxBytes and deletes body files
+// no longer referenced by the index, such as those left behind by a process that
+// was interrupted.
+func (d *DiskCache) Sweep() error {
+	var err error
+	updateErr := d.update(func(index diskIndex) bool {
+		now := time.Now()
+		for key, record := range index {
+			if d.expired(record, now) {
+				delete(index, key)
+			}
+		}
+		d.evict(index)
+		err = d.removeOrphans(index)
+		return true
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+	if err != nil {
+		return fmt.Errorf("removing unreferenced cache files: %w", err)
+	}
+	return nil
+}
+
+// Size returns the total size of the stored bodies.
+func (d *DiskCache) Size() (int64, error) {
+	var size int64
+	err := d.update(func(index diskIndex) bool {
+		size = index.size()
+		return false
+	})
+	return size, err
+}
+
+// update runs fn on the index while holding the lock, and saves the index when fn
+// reports a change. Body files no longer referenced by the index are then removed.
+func (d *DiskCache) update(fn func(index diskIndex) bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	unlock, err := lockFile(filepath.Join(d.dir, "lock"))
+	if err != nil {
+		return fmt.Errorf("locking cache: %w", err)
+	}
+	defer unlock()
+
+	index, err := d.readIndex()
+	if err != nil {
+		return err
+	}
+	before := index.blobs()
+	if !fn(index) {
+		return nil
+	}
+	if err := d.writeIndex(index); err != nil {
+		return err
+	}
+	after := index.blobs()
+	for blob := range before {
+		if !after[blob] {
+			os.Remove(d.blobPath(blob))
+		}
+	}
+	return nil
+}
+
+func (d *DiskCache) readIndex() (diskIndex, error) {
+	index := make(diskIndex)
+	data, err := os.ReadFile(filepath.Join(d.dir, "index.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache index: %w", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		// A corrupted index only loses the cached responses.
+		return make(diskIndex), nil
+	}
+	return index, nil
+}
+
+func (d *DiskCache) writeIndex(index diskIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("encoding cache index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(d.dir, "index.json"), data); err != nil {
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskCache) writeBlob(name string, body []byte) error {
+	path := d.blobPath(name)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, body)
+}
+
+// removeOrphans deletes the body files not referenced by the index.
+func (d *DiskCache) removeOrphans(index diskIndex) error {
+	referenced := index.blobs()
+	return filepath.WalkDir(filepath.Join(d.dir, "blobs"), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && !referenced[entry.Name()] {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// evict removes the least recently used entries until the bodies fit in MaxBytes.
+func (d *DiskCache) evict(index diskIndex) {
+	if d.MaxBytes <= 0 {
+		return
+	}
+	size := index.size()
+	if size <= d.MaxBytes {
+		return
+	}
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return index[a].AccessedAt.Compare(index[b].AccessedAt)
+	})
+	for _, key := range keys {
+		if size <= d.MaxBytes {
+			break
+		}
+		record := index[key]
+		delete(index, key)
+		if !index.references(record.Blob) {
+			size -= record.Size
+		}
+	}
+}
+
+func (d *DiskCache) expired(record *diskEntry, now time.Time) bool {
+	return d.TTL > 0 && now.Sub(record.StoredAt) > d.TTL
+}
+
+func (d *DiskCache) blobPath(name string) string {
+	return filepath.Join(d.dir, "blobs", name[:2], name)
+}
+
+// size returns the total size of the body files referenced by the index.
+func (index diskIndex) size() int64 {
+	seen := make(map[string]bool, len(index))
+	var size int64
+	for _, record := range index {
+		if !seen[record.Blob] {
+			seen[record.Blob] = true
+			size += record.Size
+		}
+	}
+	return size
+}
+
+func (index diskIndex) blobs() map[string]bool {
+	blobs := make(map[string]bool, len(index))
+	for _, record := range index {
+		blobs[record.Blob] = true
+	}
+	return blobs
+}
+
+func (index diskIndex) references(blob string) bool {
+	for _, record := range index {
+		if record.Blob == blob {
+			return true
+		}
+	}
+	return false
+}
+
+func blobName(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path, so
+// readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
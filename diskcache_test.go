@@ -0,0 +1,148 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countBlobs(t *testing.T, dir string) int {
+	count := 0
+	err := filepath.WalkDir(filepath.Join(dir, "blobs"), func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			count++
+		}
+		return err
+	})
+	assert.Nil(t, err)
+	return count
+}
+
+func TestSnowyDiskCache(t *testing.T) {
+	t.Run("persists between processes", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			json.NewEncoder(w).Encode(TestResponse{Message: "persisted"})
+		}))
+		defer ts.Close()
+
+		dir := t.TempDir()
+		for range 2 {
+			store, err := snowy.NewDiskCache(dir, 1<<20)
+			assert.Nil(t, err)
+			config := cachedConfig(snowy.NewCache(store))
+			res, err := snowy.Get[TestResponse](config, ts.URL, snowy.Headers{"Accept-Language": "en"}, snowy.RequestData{})
+			assert.Nil(t, err)
+			assert.Equal(t, "persisted", res.Data.Message)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("serves stale responses offline", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=0")
+			json.NewEncoder(w).Encode(TestResponse{Message: "offline"})
+		}))
+
+		store, err := snowy.NewDiskCache(t.TempDir(), 1<<20)
+		assert.Nil(t, err)
+		cache := snowy.NewCache(store)
+		cache.StaleIfError = time.Hour
+		config := cachedConfig(cache)
+		_, err = snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		ts.Close()
+
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "offline", res.Data.Message)
+	})
+
+	t.Run("stores bodies by content", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := snowy.NewDiskCache(dir, 0)
+		assert.Nil(t, err)
+		store.Set("a", &snowy.CacheEntry{StatusCode: http.StatusOK, Body: []byte("same")})
+		store.Set("b", &snowy.CacheEntry{StatusCode: http.StatusOK, Body: []byte("same")})
+		assert.Equal(t, 1, countBlobs(t, dir))
+
+		store.Delete("a")
+		entry, ok := store.Get("b")
+		assert.True(t, ok)
+		assert.Equal(t, "same", string(entry.Body))
+		store.Delete("b")
+		assert.Equal(t, 0, countBlobs(t, dir))
+	})
+
+	t.Run("evicts least recently used entries", func(t *testing.T) {
+		store, err := snowy.NewDiskCache(t.TempDir(), 250)
+		assert.Nil(t, err)
+		store.Set("a", &snowy.CacheEntry{Body: []byte(fmt.Sprintf("%0100d", 1))})
+		store.Set("b", &snowy.CacheEntry{Body: []byte(fmt.Sprintf("%0100d", 2))})
+		_, ok := store.Get("a")
+		assert.True(t, ok)
+
+		store.Set("c", &snowy.CacheEntry{Body: []byte(fmt.Sprintf("%0100d", 3))})
+		_, ok = store.Get("b")
+		assert.False(t, ok)
+		_, ok = store.Get("a")
+		assert.True(t, ok)
+		size, err := store.Size()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(200), size)
+	})
+
+	t.Run("sweeps expired entries and orphaned files", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := snowy.NewDiskCache(dir, 0)
+		assert.Nil(t, err)
+		store.Set("old", &snowy.CacheEntry{Body: []byte("old")})
+		orphan := filepath.Join(dir, "blobs", "ff", "ff-orphan")
+		assert.Nil(t, os.MkdirAll(filepath.Dir(orphan), 0o755))
+		assert.Nil(t, os.WriteFile(orphan, []byte("orphan"), 0o644))
+
+		time.Sleep(20 * time.Millisecond)
+		store.TTL = 10 * time.Millisecond
+		assert.Nil(t, store.Sweep())
+		_, ok := store.Get("old")
+		assert.False(t, ok)
+		assert.Equal(t, 0, countBlobs(t, dir))
+	})
+
+	t.Run("concurrent stores share the directory", func(t *testing.T) {
+		dir := t.TempDir()
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store, err := snowy.NewDiskCache(dir, 0)
+				assert.Nil(t, err)
+				store.Set(fmt.Sprint(i), &snowy.CacheEntry{Body: []byte(fmt.Sprint(i))})
+			}()
+		}
+		wg.Wait()
+
+		store, err := snowy.NewDiskCache(dir, 0)
+		assert.Nil(t, err)
+		for i := range 8 {
+			entry, ok := store.Get(fmt.Sprint(i))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprint(i), string(entry.Body))
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package snowy
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when downloaded content does not match the
+// expected SHA-256 checksum or the digest announced by the server.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+type DownloadOptions struct {
+	SHA256     string                     // Expected hex encoded SHA-256 of the content
+	MaxResumes int                        // Times an interrupted transfer is resumed, defaults to 3; negative disables resuming
+	Progress   func(written, total int64) // Called after every write, total is -1 when unknown
+}
+
+type DownloadResult struct {
+	StatusCode int
+	Headers    http.Header
+	Size       int64  // Size of the downloaded content
+	SHA256     string // Hex encoded SHA-256 of the content
+}
+
+// Download streams the response body of a GET request to dst. Interrupted
+// transfers are resumed with a Range request, guarded by If-Range when the server
+// provides an ETag or Last-Modified validator. If the content changed in between,
+// the transfer fails because dst cannot be rewound; DownloadFile restarts instead.
+//
+// The content is verified against DownloadOptions.SHA256 when set, and otherwise
+// against a SHA-256 Digest or Repr-Digest header or a Content-MD5 header sent by
+// the server. Config.Timeout only limits the time to receive the response headers.
+func Download(config Config, url string, headers map[string]string, dst io.Writer, opts DownloadOptions) (*DownloadResult, error) {
+	d := newDownload(config, url, headers, opts)
+	d.dst = dst
+	return d.run()
+}
+
+// DownloadFile downloads into path. The content is written to path+".part" and only
+// renamed to path once complete and verified, so an interrupted download resumes
+// from where it stopped the next time DownloadFile is called for the same path.
+func DownloadFile(config Config, url string, headers map[string]string, path string, opts DownloadOptions) (*DownloadResult, error) {
+	partPath := path + ".part"
+	validatorPath := partPath + ".validator"
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening download file: %w", err)
+	}
+	defer file.Close()
+
+	d := newDownload(config, url, headers, opts)
+	d.dst = file
+	d.reset = func() error {
+		os.Remove(validatorPath)
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		_, err := file.Seek(0, io.SeekStart)
+		return err
+	}
+	d.onValidator = func(validator string) error {
+		return os.WriteFile(validatorPath, []byte(validator), 0o644)
+	}
+
+	if validator, err := os.ReadFile(validatorPath); err == nil && len(validator) > 0 {
+		n, err := io.Copy(d.sha, file)
+		if err != nil {
+			return nil, fmt.Errorf("reading partial download: %w", err)
+		}
+		d.written, d.validator = n, string(validator)
+	} else if err := d.reset(); err != nil {
+		return nil, fmt.Errorf("truncating download file: %w", err)
+	}
+
+	result, err := d.run()
+	if err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			file.Close()
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+		}
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("closing download file: %w", err)
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return nil, fmt.Errorf("renaming download file: %w", err)
+	}
+	os.Remove(validatorPath)
+	return result, nil
+}
+
+type download struct {
+	config  Config
+	url     string
+	headers map[string]string
+	opts    DownloadOptions
+
+	dst         io.Writer
+	reset       func() error // Rewinds dst, nil when it cannot be rewound
+	onValidator func(validator string) error
+
+	written   int64
+	total     int64
+	validator string
+	sha       hash.Hash
+	md5       hash.Hash
+	single    bool // Whether the content was received in a single full response
+}
+
+func newDownload(config Config, url string, headers map[string]string, opts DownloadOptions) *download {
+	if opts.MaxResumes == 0 {
+		opts.MaxResumes = 3
+	}
+	return &download{
+		config:  config.withDefaults(),
+		url:     url,
+		headers: headers,
+		opts:    opts,
+		total:   -1,
+		sha:     sha256.New(),
+		md5:     md5.New(),
+	}
+}
+
+func (d *download) run() (*DownloadResult, error) {
+	client, release, err := streamingClient(d.config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var res *http.Response
+	for resumes := 0; ; resumes++ {
+		var err error
+		var resumable bool
+		res, err = d.request(client)
+		if err == nil {
+			resumable, err = d.copy(res)
+		}
+		if err == nil {
+			break
+		}
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// The previous attempt already received the whole content.
+			if _, total, ok := parseContentRange(reqErr.Headers.Get("Content-Range")); ok && total == d.written {
+				res = &http.Response{StatusCode: reqErr.StatusCode, Header: reqErr.Headers}
+				break
+			}
+		}
+		if !resumable || resumes >= d.opts.MaxResumes || d.config.Ctx.Err() != nil {
+			return nil, err
+		}
+	}
+
+	sum := d.sha.Sum(nil)
+	if err := d.verify(sum, res.Header); err != nil {
+		return nil, err
+	}
+	return &DownloadResult{
+		StatusCode: res.StatusCode,
+		Headers:    res.Header,
+		Size:       d.written,
+		SHA256:     hex.EncodeToString(sum),
+	}, nil
+}
+
+func (d *download) request(client *http.Client) (*http.Response, error) {
+	headers := maps.Clone(d.headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	if _, ok := headers["Accept"]; !ok {
+		headers["Accept"] = "*/*"
+	}
+	if d.written > 0 {
+		headers["Range"] = fmt.Sprintf("bytes=%d-", d.written)
+		if d.validator != "" {
+			headers["If-Range"] = d.validator
+		}
+	}
+	res, _, err := execute(d.config.Ctx, d.config, client, 0, http.MethodGet, d.url, headers, nil)
+	return res, err
+}
+
+// copy writes the body of res to the destination, and reports whether a failed
+// transfer can be resumed.
+func (d *download) copy(res *http.Response) (bool, error) {
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPartialContent {
+		start, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || start != d.written {
+			return false, fmt.Errorf("unexpected content range: %q", res.Header.Get("Content-Range"))
+		}
+		d.total = total
+		d.single = false
+	} else {
+		validator := downloadValidator(res.Header)
+		if d.written > 0 && (validator == "" || validator != d.validator) {
+			if d.reset == nil {
+				return false, errors.New("content changed during download and the destination cannot be rewound")
+			}
+			if err := d.reset(); err != nil {
+				return false, fmt.Errorf("restarting download: %w", err)
+			}
+			d.written = 0
+			d.sha.Reset()
+		}
+		if d.written > 0 {
+			// The server ignored the Range header, skip what was already written.
+			if _, err := io.CopyN(io.Discard, res.Body, d.written); err != nil {
+				return true, fmt.Errorf("reading response body: %w", err)
+			}
+		} else if validator != d.validator {
+			d.validator = validator
+			if d.onValidator != nil && validator != "" {
+				if err := d.onValidator(validator); err != nil {
+					return false, fmt.Errorf("saving download validator: %w", err)
+				}
+			}
+		}
+		d.single = d.written == 0
+		d.md5.Reset()
+		d.total = -1
+		if res.ContentLength >= 0 {
+			d.total = d.written + res.ContentLength
+		}
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := res.Body.Read(buf)
+		if n > 0 {
+			if _, err := d.dst.Write(buf[:n]); err != nil {
+				return false, fmt.Errorf("writing download: %w", err)
+			}
+			d.sha.Write(buf[:n])
+			d.md5.Write(buf[:n])
+			d.written += int64(n)
+			if d.opts.Progress != nil {
+				d.opts.Progress(d.written, d.total)
+			}
+		}
+		if readErr == io.EOF {
+			return false, nil
+		}
+		if readErr != nil {
+			return true, fmt.Errorf("reading response body: %w", readErr)
+		}
+	}
+}
+
+func (d *download) verify(sum []byte, headers http.Header) error {
+	if d.opts.SHA256 != "" {
+		if !strings.EqualFold(d.opts.SHA256, hex.EncodeToString(sum)) {
+			return fmt.Errorf("%w: expected sha-256 %s, got %x", ErrChecksumMismatch, d.opts.SHA256, sum)
+		}
+		return nil
+	}
+	if expected, ok := headerSHA256(headers); ok {
+		if !bytes.Equal(expected, sum) {
+			return fmt.Errorf("%w: expected sha-256 %x, got %x", ErrChecksumMismatch, expected, sum)
+		}
+		return nil
+	}
+	if value := headers.Get("Content-MD5"); value != "" && d.single {
+		expected, err := base64.StdEncoding.DecodeString(value)
+		if got := d.md5.Sum(nil); err != nil || !bytes.Equal(expected, got) {
+			return fmt.Errorf("%w: expected md5 %s, got %s", ErrChecksumMismatch, value, base64.StdEncoding.EncodeToString(got))
+		}
+	}
+	return nil
+}
+
+// headerSHA256 returns the SHA-256 digest of the representation announced in a
+// Digest (RFC 3230) or Repr-Digest (RFC 9530) header.
+func headerSHA256(headers http.Header) ([]byte, bool) {
+	for _, value := range headers.Values("Digest") {
+		for _, digest := range strings.Split(value, ",") {
+			algorithm, encoded, _ := strings.Cut(strings.TrimSpace(digest), "=")
+			if strings.EqualFold(algorithm, "sha-256") {
+				if sum, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+					return sum, true
+				}
+			}
+		}
+	}
+	for _, value := range headers.Values("Repr-Digest") {
+		for _, digest := range strings.Split(value, ",") {
+			algorithm, encoded, _ := strings.Cut(strings.TrimSpace(digest), "=")
+			if strings.EqualFold(algorithm, "sha-256") {
+				if sum, err := base64.StdEncoding.DecodeString(strings.Trim(encoded, ":")); err == nil {
+					return sum, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// downloadValidator returns the validator to send in If-Range: a strong ETag, or
+// the Last-Modified date. Weak ETags cannot be used for range requests.
+func downloadValidator(headers http.Header) string {
+	if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return headers.Get("Last-Modified")
+}
+
+// parseContentRange parses "bytes first-last/total" and "bytes */total". The total
+// is -1 when it is unknown.
+func parseContentRange(value string) (int64, int64, bool) {
+	value, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	span, size, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return 0, 0, false
+	}
+	total := int64(-1)
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if span == "*" {
+		return 0, total, true
+	}
+	first, _, ok := strings.Cut(span, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
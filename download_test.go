@@ -0,0 +1,252 @@
+package snowy_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var downloadContent = []byte(strings.Repeat("snowy download content ", 4096))
+
+func downloadSHA256(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSnowyDownload(t *testing.T) {
+	t.Run("streams to writer with progress and checksum", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		var buf bytes.Buffer
+		var last, total int64
+		res, err := snowy.Download(snowy.Config{}, ts.URL, nil, &buf, snowy.DownloadOptions{
+			SHA256: downloadSHA256(downloadContent),
+			Progress: func(w, t int64) {
+				last, total = w, t
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, int64(len(downloadContent)), res.Size)
+		assert.Equal(t, downloadSHA256(downloadContent), res.SHA256)
+		assert.Equal(t, downloadContent, buf.Bytes())
+		assert.Equal(t, int64(len(downloadContent)), last)
+		assert.Equal(t, int64(len(downloadContent)), total)
+	})
+
+	t.Run("resumes interrupted transfer", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			if calls.Add(1) == 1 {
+				// The transfer is cut off after half of the content.
+				w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)))
+				w.WriteHeader(http.StatusOK)
+				w.Write(downloadContent[:len(downloadContent)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		var buf bytes.Buffer
+		res, err := snowy.Download(snowy.Config{}, ts.URL, nil, &buf, snowy.DownloadOptions{
+			SHA256: downloadSHA256(downloadContent),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, downloadContent, buf.Bytes())
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("does not resume when disabled", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			if calls.Add(1) == 1 {
+				// The transfer is cut off after half of the content.
+				w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)))
+				w.WriteHeader(http.StatusOK)
+				w.Write(downloadContent[:len(downloadContent)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		var buf bytes.Buffer
+		res, err := snowy.Download(snowy.Config{}, ts.URL, nil, &buf, snowy.DownloadOptions{MaxResumes: -1})
+		assert.Nil(t, res)
+		assert.ErrorContains(t, err, "reading response body")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("verifies digest headers", func(t *testing.T) {
+		sum := sha256.Sum256(downloadContent)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+			w.Write(downloadContent[1:])
+		}))
+		defer ts.Close()
+
+		var buf bytes.Buffer
+		res, err := snowy.Download(snowy.Config{}, ts.URL, nil, &buf, snowy.DownloadOptions{})
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, snowy.ErrChecksumMismatch)
+	})
+
+	t.Run("verifies content md5", func(t *testing.T) {
+		sum := md5.Sum(downloadContent)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+			w.Write(downloadContent)
+		}))
+		defer ts.Close()
+
+		var buf bytes.Buffer
+		res, err := snowy.Download(snowy.Config{}, ts.URL, nil, &buf, snowy.DownloadOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(downloadContent)), res.Size)
+	})
+
+	t.Run("unexpected status code", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		defer ts.Close()
+
+		var buf bytes.Buffer
+		_, err := snowy.Download(snowy.Config{}, ts.URL, nil, &buf, snowy.DownloadOptions{})
+		assert.IsType(t, &snowy.RequestError{}, err)
+		assert.Equal(t, 0, buf.Len())
+	})
+}
+
+func TestSnowyDownloadFile(t *testing.T) {
+	t.Run("downloads to path", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			if calls.Add(1) == 1 {
+				// The transfer is cut off after half of the content.
+				w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)))
+				w.WriteHeader(http.StatusOK)
+				w.Write(downloadContent[:len(downloadContent)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		res, err := snowy.DownloadFile(snowy.Config{}, ts.URL, nil, path, snowy.DownloadOptions{
+			SHA256: downloadSHA256(downloadContent),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(downloadContent)), res.Size)
+		content, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, downloadContent, content)
+		assert.NoFileExists(t, path+".part")
+		assert.NoFileExists(t, path+".part.validator")
+	})
+
+	t.Run("resumes previous partial download", func(t *testing.T) {
+		var ranges []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		assert.Nil(t, os.WriteFile(path+".part", downloadContent[:1000], 0o644))
+		assert.Nil(t, os.WriteFile(path+".part.validator", []byte(`"v1"`), 0o644))
+
+		res, err := snowy.DownloadFile(snowy.Config{}, ts.URL, nil, path, snowy.DownloadOptions{
+			SHA256: downloadSHA256(downloadContent),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, []string{`bytes=1000- "v1"`}, ranges)
+		content, _ := os.ReadFile(path)
+		assert.Equal(t, downloadContent, content)
+	})
+
+	t.Run("restarts when content changed", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v2"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		assert.Nil(t, os.WriteFile(path+".part", []byte("stale content"), 0o644))
+		assert.Nil(t, os.WriteFile(path+".part.validator", []byte(`"v1"`), 0o644))
+
+		res, err := snowy.DownloadFile(snowy.Config{}, ts.URL, nil, path, snowy.DownloadOptions{
+			SHA256: downloadSHA256(downloadContent),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		content, _ := os.ReadFile(path)
+		assert.Equal(t, downloadContent, content)
+	})
+
+	t.Run("already complete partial download", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		assert.Nil(t, os.WriteFile(path+".part", downloadContent, 0o644))
+		assert.Nil(t, os.WriteFile(path+".part.validator", []byte(`"v1"`), 0o644))
+
+		res, err := snowy.DownloadFile(snowy.Config{}, ts.URL, nil, path, snowy.DownloadOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, downloadSHA256(downloadContent), res.SHA256)
+		content, _ := os.ReadFile(path)
+		assert.Equal(t, downloadContent, content)
+	})
+
+	t.Run("removes partial file on checksum mismatch", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}))
+		defer ts.Close()
+
+		path := filepath.Join(t.TempDir(), "artifact.bin")
+		res, err := snowy.DownloadFile(snowy.Config{}, ts.URL, nil, path, snowy.DownloadOptions{
+			SHA256: downloadSHA256([]byte("other")),
+		})
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, snowy.ErrChecksumMismatch)
+		assert.NoFileExists(t, path)
+		assert.NoFileExists(t, path+".part")
+		assert.NoFileExists(t, path+".part.validator")
+	})
+}
@@ -0,0 +1,154 @@
+package snowy
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// ErrorDecoder turns the body of a response with an unacceptable status code into
+// an error. The error is available through RequestError.Err and errors.As.
+type ErrorDecoder interface {
+	DecodeError(res *http.Response, body []byte) error
+}
+
+// APIError is the typed error body of a response with an unacceptable status code.
+// Data is nil when the body is empty or cannot be decoded into E.
+type APIError[E any] struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+	Data       *E
+}
+
+func (e *APIError[E]) Error() string {
+	return fmt.Sprintf("message: unexpected status code: %d", e.StatusCode)
+}
+
+type errorBody[E any] struct{}
+
+// ErrorBody returns an ErrorDecoder that decodes JSON error bodies into E:
+//
+//	type APIFailure struct {
+//		Code    string `json:"code"`
+//		Message string `json:"message"`
+//	}
+//
+//	config := snowy.Config{ErrorDecoder: snowy.ErrorBody[APIFailure]()}
+//
+//	_, err := snowy.Get[UserResponse](config, "https://api.example.com/users/1", nil, snowy.RequestData{})
+//	var apiErr *snowy.APIError[APIFailure]
+//	if errors.As(err, &apiErr) && apiErr.Data != nil {
+//		fmt.Println(apiErr.Data.Code)
+//	}
+func ErrorBody[E any]() ErrorDecoder {
+	return errorBody[E]{}
+}
+
+func (errorBody[E]) DecodeError(res *http.Response, body []byte) error {
+	apiErr := &APIError[E]{
+		StatusCode: res.StatusCode,
+		Headers:    res.Header,
+		Body:       body,
+	}
+	var v E
+	if json.Unmarshal(body, &v) == nil {
+		apiErr.Data = &v
+	}
+	return apiErr
+}
+
+// ProblemDetails is an RFC 9457 problem details object, decoded from responses
+// with an application/problem+json content type. Members other than the standard
+// ones are collected in Extensions.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// UnmarshalJSON decodes a problem details object. As required by RFC 9457, a
+// standard member whose value has the wrong type is ignored.
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*p = ProblemDetails{Type: "about:blank"}
+	for name, value := range members {
+		switch name {
+		case "type":
+			decodeProblemMember(value, &p.Type)
+		case "title":
+			decodeProblemMember(value, &p.Title)
+		case "status":
+			decodeProblemMember(value, &p.Status)
+		case "detail":
+			decodeProblemMember(value, &p.Detail)
+		case "instance":
+			decodeProblemMember(value, &p.Instance)
+		default:
+			var ext any
+			if err := json.Unmarshal(value, &ext); err != nil {
+				return fmt.Errorf("decoding problem details member %q: %w", name, err)
+			}
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any)
+			}
+			p.Extensions[name] = ext
+		}
+	}
+	return nil
+}
+
+// decodeProblemMember sets dst to value, leaving it untouched when the types do
+// not match.
+func decodeProblemMember[T any](value json.RawMessage, dst *T) {
+	var v T
+	if json.Unmarshal(value, &v) == nil {
+		*dst = v
+	}
+}
+
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	if p.Type != "" {
+		members["type"] = p.Type
+	}
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+func (p *ProblemDetails) message() string {
+	switch {
+	case p.Title != "" && p.Detail != "":
+		return p.Title + ": " + p.Detail
+	case p.Title != "":
+		return p.Title
+	default:
+		return p.Detail
+	}
+}
+
+func isProblemDetails(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/problem+json"
+}
@@ -0,0 +1,170 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type FakeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestSnowyErrorBody(t *testing.T) {
+	t.Run("decodes typed error body", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Trace", "trace")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, `{"code":"invalid_email","message":"email is invalid"}`)
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{ErrorDecoder: snowy.ErrorBody[FakeError]()}
+		res, err := snowy.Post[TestResponse](config, ts.URL, snowy.Headers{}, snowy.RequestData{})
+		assert.Nil(t, res)
+
+		var apiErr *snowy.APIError[FakeError]
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+		assert.Equal(t, "trace", apiErr.Headers.Get("X-Trace"))
+		assert.Equal(t, `{"code":"invalid_email","message":"email is invalid"}`, string(apiErr.Body))
+		assert.Equal(t, "invalid_email", apiErr.Data.Code)
+		assert.Equal(t, "email is invalid", apiErr.Data.Message)
+		assert.Equal(t, "message: unexpected status code: 422", apiErr.Error())
+
+		var reqErr *snowy.RequestError
+		assert.True(t, errors.As(err, &reqErr))
+		assert.Equal(t, http.StatusUnprocessableEntity, reqErr.StatusCode)
+		assert.Equal(t, 1, reqErr.Attempts)
+	})
+
+	t.Run("keeps raw body when it cannot be decoded", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "bad gateway")
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{ErrorDecoder: snowy.ErrorBody[FakeError]()}
+		_, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+
+		var apiErr *snowy.APIError[FakeError]
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Nil(t, apiErr.Data)
+		assert.Equal(t, "bad gateway", string(apiErr.Body))
+		assert.Equal(t, "bad gateway", err.(*snowy.RequestError).Response)
+	})
+
+	t.Run("without decoder", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"code":"not_found"}`)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		var apiErr *snowy.APIError[FakeError]
+		assert.False(t, errors.As(err, &apiErr))
+		assert.Equal(t, `{"code":"not_found"}`, string(err.(*snowy.RequestError).Body))
+	})
+}
+
+func TestSnowyProblemDetails(t *testing.T) {
+	t.Run("decodes problem details", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{
+				"type": "https://example.com/probs/out-of-credit",
+				"title": "You do not have enough credit.",
+				"status": 403,
+				"detail": "Your current balance is 30, but that costs 50.",
+				"instance": "/account/12345/msgs/abc",
+				"balance": 30
+			}`)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		assert.IsType(t, &snowy.RequestError{}, err)
+		problem := err.(*snowy.RequestError).Problem
+		assert.NotNil(t, problem)
+		assert.Equal(t, "https://example.com/probs/out-of-credit", problem.Type)
+		assert.Equal(t, "You do not have enough credit.", problem.Title)
+		assert.Equal(t, http.StatusForbidden, problem.Status)
+		assert.Equal(t, "Your current balance is 30, but that costs 50.", problem.Detail)
+		assert.Equal(t, "/account/12345/msgs/abc", problem.Instance)
+		assert.Equal(t, map[string]any{"balance": float64(30)}, problem.Extensions)
+		assert.Equal(t, "message: You do not have enough credit.: Your current balance is 30, but that costs 50.", err.Error())
+	})
+
+	t.Run("defaults type to about:blank", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"title": "Not Found"}`)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		problem := err.(*snowy.RequestError).Problem
+		assert.Equal(t, "about:blank", problem.Type)
+		assert.Nil(t, problem.Extensions)
+		assert.Equal(t, "message: Not Found", err.Error())
+	})
+
+	t.Run("ignores members of the wrong type", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"type": 42, "title": "Bad input", "status": "400", "detail": "Name is required."}`)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		problem := err.(*snowy.RequestError).Problem
+		assert.NotNil(t, problem)
+		assert.Equal(t, "about:blank", problem.Type)
+		assert.Equal(t, "Bad input", problem.Title)
+		assert.Equal(t, 0, problem.Status)
+		assert.Equal(t, "message: Bad input: Name is required.", err.Error())
+	})
+
+	t.Run("ignores other content types", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"title": "Not Found"}`)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err.(*snowy.RequestError).Problem)
+		assert.Equal(t, "message: unexpected status code: 404", err.Error())
+	})
+
+	t.Run("round trips extension members", func(t *testing.T) {
+		problem := snowy.ProblemDetails{
+			Type:       "about:blank",
+			Title:      "Conflict",
+			Status:     http.StatusConflict,
+			Extensions: map[string]any{"resource": "user"},
+		}
+		data, err := json.Marshal(problem)
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Conflict","status":409,"resource":"user"}`, string(data))
+
+		var decoded snowy.ProblemDetails
+		assert.Nil(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, problem, decoded)
+	})
+}
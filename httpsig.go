@@ -0,0 +1,653 @@
+package snowy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned when an HTTP message signature does not verify.
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+// MessageSigner signs requests with HTTP Message Signatures (RFC 9421), adding the
+// Signature-Input and Signature headers. Key is a []byte secret for HMAC-SHA256,
+// an ed25519.PrivateKey or an *ecdsa.PrivateKey on the P-256 or P-384 curve.
+//
+//	signer := &snowy.MessageSigner{KeyID: "partner-key", Key: privateKey}
+//	config := snowy.Config{Middleware: []snowy.Middleware{signer.Middleware()}}
+//
+// When the covered components include content-digest and the request has a body,
+// the Content-Digest header is generated before signing.
+type MessageSigner struct {
+	KeyID           string
+	Key             crypto.PrivateKey
+	Label           string        // Signature label, defaults to sig1
+	Components      []string      // Covered components, defaults to @method, @target-uri and content-digest
+	Expires         time.Duration // Add an expires parameter this long after creation
+	Tag             string        // Application specific tag parameter
+	IncludeAlg      bool          // Add the alg parameter
+	DigestAlgorithm string        // Content-Digest algorithm, sha-256 (default) or sha-512
+}
+
+// Middleware signs every attempt of a request. Add it last, so the signature
+// covers the headers set by other middleware.
+func (s *MessageSigner) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if err := s.Sign(req, time.Now()); err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+// Sign signs req as created at the given time.
+func (s *MessageSigner) Sign(req *http.Request, created time.Time) error {
+	alg, err := signatureAlgorithm(s.Key)
+	if err != nil {
+		return err
+	}
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	components := s.Components
+	if components == nil {
+		components = []string{"@method", "@target-uri"}
+		if hasBody {
+			components = append(components, "content-digest")
+		}
+	}
+	if slices.Contains(components, "content-digest") && req.Header.Get("Content-Digest") == "" {
+		digest, err := contentDigest(req, s.DigestAlgorithm)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Digest", digest)
+	}
+
+	var params strings.Builder
+	params.WriteString("(")
+	for i, component := range components {
+		if i > 0 {
+			params.WriteString(" ")
+		}
+		params.WriteString(strconv.Quote(component))
+	}
+	params.WriteString(")")
+	fmt.Fprintf(&params, ";created=%d", created.Unix())
+	if s.Expires > 0 {
+		fmt.Fprintf(&params, ";expires=%d", created.Add(s.Expires).Unix())
+	}
+	fmt.Fprintf(&params, ";keyid=%s", strconv.Quote(s.KeyID))
+	if s.IncludeAlg {
+		fmt.Fprintf(&params, ";alg=%s", strconv.Quote(alg))
+	}
+	if s.Tag != "" {
+		fmt.Fprintf(&params, ";tag=%s", strconv.Quote(s.Tag))
+	}
+
+	base, err := signatureBase(signedMessage{req: req, header: req.Header}, components, params.String())
+	if err != nil {
+		return err
+	}
+	signature, err := signMessage(s.Key, []byte(base))
+	if err != nil {
+		return err
+	}
+	label := s.Label
+	if label == "" {
+		label = "sig1"
+	}
+	req.Header.Set("Signature-Input", label+"="+params.String())
+	req.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// MessageVerifier verifies HTTP Message Signatures (RFC 9421) on responses and
+// requests. Key returns the key identified by the keyid parameter: a []byte secret
+// for HMAC-SHA256, an ed25519.PublicKey or an *ecdsa.PublicKey.
+//
+// A message is valid when one of its signatures, or the one named Label, verifies,
+// covers the Required components and is not older than MaxAge. The body is checked
+// against the Content-Digest header when it has one.
+//
+// The signature of a response covers components of the request that produced it,
+// such as "@method";req, only through the req parameter, which is the only component
+// parameter supported. They are listed in Required with a ;req suffix, as @method;req.
+type MessageVerifier struct {
+	Key      func(keyID string) (crypto.PublicKey, error)
+	Label    string        // Only verify the signature with this label
+	Required []string      // Components the signature must cover
+	MaxAge   time.Duration // Reject signatures created longer ago, zero disables the check
+}
+
+// Middleware verifies the signature of responses with an acceptable status code.
+func (v *MessageVerifier) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			res, err := next(req)
+			if err != nil {
+				return res, err
+			}
+			if err := v.VerifyResponse(res); err != nil {
+				res.Body.Close()
+				return nil, err
+			}
+			return res, nil
+		}
+	}
+}
+
+// VerifyResponse verifies the signature of res. The body is read to check its
+// digest and replaced, so it can still be consumed.
+func (v *MessageVerifier) VerifyResponse(res *http.Response) error {
+	if err := verifyContentDigest(res.Header, &res.Body); err != nil {
+		return err
+	}
+	return v.verify(signedMessage{req: res.Request, header: res.Header, status: res.StatusCode})
+}
+
+// VerifyRequest verifies the signature of a request received by a server.
+func (v *MessageVerifier) VerifyRequest(req *http.Request) error {
+	if err := verifyContentDigest(req.Header, &req.Body); err != nil {
+		return err
+	}
+	return v.verify(signedMessage{req: req, header: req.Header})
+}
+
+func (v *MessageVerifier) verify(m signedMessage) error {
+	inputs, err := parseSignatureInput(m.header.Get("Signature-Input"))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	signatures := parseSignatures(m.header.Get("Signature"))
+	if len(inputs) == 0 {
+		return fmt.Errorf("%w: message is not signed", ErrInvalidSignature)
+	}
+
+	var lastErr error
+	for _, input := range inputs {
+		if v.Label != "" && input.label != v.Label {
+			continue
+		}
+		signature, ok := signatures[input.label]
+		if !ok {
+			lastErr = fmt.Errorf("missing signature %q", input.label)
+			continue
+		}
+		if lastErr = v.verifyInput(m, input, signature); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("missing signature %q", v.Label)
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidSignature, lastErr)
+}
+
+func (v *MessageVerifier) verifyInput(m signedMessage, input signatureInput, signature []byte) error {
+	for _, required := range v.Required {
+		if !slices.Contains(input.components, required) {
+			return fmt.Errorf("signature does not cover %q", required)
+		}
+	}
+	now := time.Now()
+	if v.MaxAge > 0 {
+		seconds, err := strconv.ParseInt(input.params["created"], 10, 64)
+		if err != nil || now.Sub(time.Unix(seconds, 0)) > v.MaxAge {
+			return errors.New("signature is too old")
+		}
+	}
+	if expires, ok := input.params["expires"]; ok {
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || now.After(time.Unix(seconds, 0)) {
+			return errors.New("signature expired")
+		}
+	}
+	if v.Key == nil {
+		return errors.New("no verification key")
+	}
+	key, err := v.Key(input.params["keyid"])
+	if err != nil {
+		return fmt.Errorf("loading key %q: %w", input.params["keyid"], err)
+	}
+	if alg, ok := input.params["alg"]; ok {
+		if expected, err := signatureAlgorithm(key); err != nil || expected != alg {
+			return fmt.Errorf("algorithm %q does not match the key", alg)
+		}
+	}
+	base, err := signatureBase(m, input.components, input.raw)
+	if err != nil {
+		return err
+	}
+	return verifyMessage(key, []byte(base), signature)
+}
+
+// signedMessage is the request or response whose components are signed. For
+// responses, req is the request that produced it and may be nil.
+type signedMessage struct {
+	req    *http.Request
+	header http.Header
+	status int
+}
+
+func (m signedMessage) component(name string) (string, error) {
+	if !strings.HasPrefix(name, "@") {
+		values := m.header.Values(name)
+		if len(values) == 0 {
+			return "", fmt.Errorf("missing header %q", name)
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.TrimSpace(value)
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+	if name == "@status" {
+		if m.status == 0 {
+			return "", errors.New("@status is only available on responses")
+		}
+		return strconv.Itoa(m.status), nil
+	}
+	if m.req == nil {
+		return "", fmt.Errorf("missing request for component %q", name)
+	}
+	if m.status != 0 {
+		return "", fmt.Errorf("component %q of a response needs the req parameter", name)
+	}
+	u := m.req.URL
+	switch name {
+	case "@method":
+		return m.req.Method, nil
+	case "@target-uri":
+		return m.scheme() + "://" + m.authority() + m.requestTarget(), nil
+	case "@authority":
+		return m.authority(), nil
+	case "@scheme":
+		return m.scheme(), nil
+	case "@request-target":
+		return m.requestTarget(), nil
+	case "@path":
+		if path := u.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + u.RawQuery, nil
+	}
+	return "", fmt.Errorf("unsupported component %q", name)
+}
+
+// scheme returns the lowercase scheme. Requests received by a server have no
+// scheme in their URL, so it is derived from the connection.
+func (m signedMessage) scheme() string {
+	if m.req.URL.Scheme != "" {
+		return strings.ToLower(m.req.URL.Scheme)
+	}
+	if m.req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// authority returns the lowercase host, without the default port of the scheme.
+func (m signedMessage) authority() string {
+	host := m.req.Host
+	if host == "" {
+		host = m.req.URL.Host
+	}
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		scheme := m.scheme()
+		if scheme == "http" && port == "80" || scheme == "https" && port == "443" {
+			return h
+		}
+	}
+	return host
+}
+
+func (m signedMessage) requestTarget() string {
+	target := m.req.URL.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if m.req.URL.RawQuery != "" || m.req.URL.ForceQuery {
+		target += "?" + m.req.URL.RawQuery
+	}
+	return target
+}
+
+// signatureBase builds the signature base (RFC 9421, section 2.5) from the covered
+// components and the serialized signature parameters. Components with a ;req suffix
+// are taken from the request that produced the response m.
+func signatureBase(m signedMessage, components []string, params string) (string, error) {
+	var b strings.Builder
+	for _, component := range components {
+		name, req := strings.CutSuffix(component, ";req")
+		id := strconv.Quote(name)
+		source := m
+		if req {
+			if m.status == 0 {
+				return "", fmt.Errorf("the req parameter of %q is only valid on responses", name)
+			}
+			if m.req == nil {
+				return "", fmt.Errorf("missing request for component %q", component)
+			}
+			source, id = signedMessage{req: m.req, header: m.req.Header}, id+";req"
+		}
+		value, err := source.component(name)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(id)
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(params)
+	return b.String(), nil
+}
+
+func signatureAlgorithm(key any) (string, error) {
+	switch key := key.(type) {
+	case []byte:
+		return "hmac-sha256", nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return "ed25519", nil
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(key.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(key.Curve)
+	}
+	return "", fmt.Errorf("unsupported signature key type %T", key)
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return "ecdsa-p256-sha256", nil
+	case elliptic.P384():
+		return "ecdsa-p384-sha384", nil
+	}
+	return "", fmt.Errorf("unsupported ECDSA curve %s", curve.Params().Name)
+}
+
+func ecdsaDigest(curve elliptic.Curve, data []byte) []byte {
+	if curve == elliptic.P384() {
+		sum := sha512.Sum384(data)
+		return sum[:]
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func signMessage(key crypto.PrivateKey, base []byte) ([]byte, error) {
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, base), nil
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, ecdsaDigest(key.Curve, base))
+		if err != nil {
+			return nil, fmt.Errorf("signing message: %w", err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	}
+	return nil, fmt.Errorf("unsupported signature key type %T", key)
+}
+
+func verifyMessage(key crypto.PublicKey, base, signature []byte) error {
+	valid := false
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(base)
+		valid = hmac.Equal(mac.Sum(nil), signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, base, signature)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(key, ecdsaDigest(key.Curve, base), r, s)
+		}
+	default:
+		return fmt.Errorf("unsupported signature key type %T", key)
+	}
+	if !valid {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ContentDigest sets the Content-Digest header (RFC 9530) of requests with a body,
+// using the sha-256 or sha-512 algorithm.
+func ContentDigest(algorithm string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Body != nil && req.Body != http.NoBody {
+				digest, err := contentDigest(req, algorithm)
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Content-Digest", digest)
+			}
+			return next(req)
+		}
+	}
+}
+
+func contentDigest(req *http.Request, algorithm string) (string, error) {
+	h, algorithm, err := digestHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if err := copyBody(h, req); err != nil {
+		return "", fmt.Errorf("reading body to digest: %w", err)
+	}
+	return algorithm + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":", nil
+}
+
+func digestHash(algorithm string) (hash.Hash, string, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "sha-256":
+		return sha256.New(), "sha-256", nil
+	case "sha-512":
+		return sha512.New(), "sha-512", nil
+	}
+	return nil, "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+}
+
+// verifyContentDigest checks the body against the supported digests listed in the
+// Content-Digest header, replacing the body with the bytes read.
+func verifyContentDigest(header http.Header, body *io.ReadCloser) error {
+	value := header.Get("Content-Digest")
+	if value == "" {
+		return nil
+	}
+	var data []byte
+	if *body != nil && *body != http.NoBody {
+		var err error
+		data, err = io.ReadAll(*body)
+		(*body).Close()
+		if err != nil {
+			return fmt.Errorf("reading body to digest: %w", err)
+		}
+		*body = io.NopCloser(bytes.NewReader(data))
+	}
+	for _, member := range strings.Split(value, ",") {
+		algorithm, encoded, _ := strings.Cut(strings.TrimSpace(member), "=")
+		h, _, err := digestHash(algorithm)
+		if err != nil {
+			continue
+		}
+		expected, err := base64.StdEncoding.DecodeString(strings.Trim(encoded, ":"))
+		h.Write(data)
+		if err != nil || !hmac.Equal(expected, h.Sum(nil)) {
+			return fmt.Errorf("%w: content digest mismatch", ErrInvalidSignature)
+		}
+		return nil
+	}
+	return nil
+}
+
+type signatureInput struct {
+	label      string
+	components []string
+	params     map[string]string
+	raw        string // Serialized inner list, as used in the signature base
+}
+
+// parseSignatureInput parses the Signature-Input dictionary. The req parameter of
+// a component identifier is kept as a ;req suffix of its name, and other component
+// parameters are not supported.
+func parseSignatureInput(value string) ([]signatureInput, error) {
+	var inputs []signatureInput
+	i := 0
+	skipSpaces := func() {
+		for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+			i++
+		}
+	}
+	for {
+		skipSpaces()
+		if i >= len(value) {
+			return inputs, nil
+		}
+		eq := strings.IndexByte(value[i:], '=')
+		if eq < 0 {
+			return nil, errors.New("malformed Signature-Input")
+		}
+		input := signatureInput{label: strings.TrimSpace(value[i : i+eq]), params: make(map[string]string)}
+		i += eq + 1
+		start := i
+		if i >= len(value) || value[i] != '(' {
+			return nil, errors.New("malformed Signature-Input")
+		}
+		i++
+		for {
+			skipSpaces()
+			if i >= len(value) {
+				return nil, errors.New("malformed Signature-Input")
+			}
+			if value[i] == ')' {
+				i++
+				break
+			}
+			item, n, err := parseQuoted(value[i:])
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			if strings.HasPrefix(value[i:], ";req") && (len(value) == i+4 || value[i+4] == ' ' || value[i+4] == ')') {
+				item += ";req"
+				i += 4
+			}
+			if i < len(value) && value[i] == ';' {
+				return nil, fmt.Errorf("unsupported component parameters on %q", item)
+			}
+			input.components = append(input.components, item)
+		}
+		for i < len(value) && value[i] == ';' {
+			i++
+			end := i
+			for end < len(value) && value[end] != '=' && value[end] != ';' && value[end] != ',' {
+				end++
+			}
+			name := strings.TrimSpace(value[i:end])
+			i = end
+			param := ""
+			if i < len(value) && value[i] == '=' {
+				i++
+				if i < len(value) && value[i] == '"' {
+					quoted, n, err := parseQuoted(value[i:])
+					if err != nil {
+						return nil, err
+					}
+					param, i = quoted, i+n
+				} else {
+					end := i
+					for end < len(value) && value[end] != ';' && value[end] != ',' {
+						end++
+					}
+					param, i = strings.TrimSpace(value[i:end]), end
+				}
+			}
+			input.params[name] = param
+		}
+		input.raw = strings.TrimSpace(value[start:i])
+		inputs = append(inputs, input)
+		skipSpaces()
+		if i < len(value) {
+			if value[i] != ',' {
+				return nil, errors.New("malformed Signature-Input")
+			}
+			i++
+		}
+	}
+}
+
+// parseQuoted parses a structured field string at the start of s, returning its
+// value and the number of bytes consumed.
+func parseQuoted(s string) (string, int, error) {
+	if s == "" || s[0] != '"' {
+		return "", 0, errors.New("malformed Signature-Input: expected string")
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, errors.New("malformed Signature-Input: unterminated string")
+			}
+			i++
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, errors.New("malformed Signature-Input: unterminated string")
+}
+
+// parseSignatures parses the Signature dictionary of byte sequences by label.
+func parseSignatures(value string) map[string][]byte {
+	signatures := make(map[string][]byte)
+	for _, member := range strings.Split(value, ",") {
+		label, encoded, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+		if signature, err := base64.StdEncoding.DecodeString(strings.Trim(encoded, ":")); err == nil {
+			signatures[label] = signature
+		}
+	}
+	return signatures
+}
@@ -0,0 +1,35 @@
+//go:build !unix
+
+package snowy
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// staleLockAge is how old a lock file must be before it is considered abandoned
+// by a process that exited without releasing it.
+const staleLockAge = 30 * time.Second
+
+// lockFile takes an exclusive lock on path by creating a lock file next to it,
+// blocking until it is available.
+func lockFile(path string) (func(), error) {
+	path += ".excl"
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
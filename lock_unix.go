@@ -0,0 +1,31 @@
+//go:build unix
+
+package snowy
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on path, blocking until it is available. The
+// lock is released by the operating system if the process exits.
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,114 @@
+package snowy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// RoundTripFunc performs a single attempt of a request. When the response status
+// code is not acceptable it returns both the response, with its body still
+// readable, and the *RequestError decoded from it.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the round trip of every attempt made by a request, giving it
+// access to the outgoing *http.Request, the raw *http.Response and the decoded error.
+// Middleware configured on Config runs in order, the first one being the outermost.
+//
+//	logging := func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+//		return func(req *http.Request) (*http.Response, error) {
+//			start := time.Now()
+//			res, err := next(req)
+//			log.Printf("%s %s took %s: %v", req.Method, req.URL, time.Since(start), err)
+//			return res, err
+//		}
+//	}
+//
+//	config := snowy.Config{Middleware: []snowy.Middleware{logging}}
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use appends middleware to the client configuration.
+func (c *Client) Use(middleware ...Middleware) {
+	c.Config.Middleware = append(slices.Clip(c.Config.Middleware), middleware...)
+}
+
+// RequestID sets header to a random identifier on requests that do not have one yet.
+func RequestID(header string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				id := make([]byte, 16)
+				rand.Read(id)
+				req.Header.Set(header, hex.EncodeToString(id))
+			}
+			return next(req)
+		}
+	}
+}
+
+// UserAgent sets the User-Agent header of every request.
+func UserAgent(userAgent string) Middleware {
+	return SetHeaders(Headers{"User-Agent": userAgent})
+}
+
+// SetHeaders sets the given headers on every request, replacing existing values.
+func SetHeaders(headers Headers) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			return next(req)
+		}
+	}
+}
+
+// copyBody writes the body of req to w without consuming it. Bodies that cannot be
+// recreated with GetBody are read into memory and replaced.
+func copyBody(w io.Writer, req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		_, err = io.Copy(w, body)
+		return err
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	_, err = w.Write(data)
+	return err
+}
+
+// replayRequest returns a copy of req that can be sent again, with a fresh body.
+// It fails when the body cannot be recreated.
+func replayRequest(req *http.Request) (*http.Request, bool) {
+	replay := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return replay, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	replay.Body = body
+	return replay, true
+}
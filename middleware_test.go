@@ -0,0 +1,147 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyMiddleware(t *testing.T) {
+	t.Run("runs in order around the request", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "first,second", r.Header.Get("X-Order"))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(TestResponse{Message: "success"})
+		}))
+		defer ts.Close()
+
+		var calls []string
+		record := func(name string) snowy.Middleware {
+			return func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+				return func(req *http.Request) (*http.Response, error) {
+					calls = append(calls, "before "+name)
+					if order := req.Header.Get("X-Order"); order != "" {
+						name = order + "," + name
+					}
+					req.Header.Set("X-Order", name)
+					res, err := next(req)
+					calls = append(calls, "after "+name)
+					return res, err
+				}
+			}
+		}
+
+		config := snowy.Config{Middleware: []snowy.Middleware{record("first"), record("second")}}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "success", res.Data.Message)
+		assert.Equal(t, []string{"before first", "before second", "after first,second", "after first"}, calls)
+	})
+
+	t.Run("sees raw response and decoded error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"error":"conflict"}`)
+		}))
+		defer ts.Close()
+
+		inspect := func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				res, err := next(req)
+				assert.Equal(t, http.StatusConflict, res.StatusCode)
+				body, readErr := io.ReadAll(res.Body)
+				assert.Nil(t, readErr)
+				assert.Equal(t, `{"error":"conflict"}`, string(body))
+				var reqErr *snowy.RequestError
+				assert.True(t, errors.As(err, &reqErr))
+				assert.Equal(t, map[string]any{"error": "conflict"}, reqErr.Response)
+				return res, err
+			}
+		}
+
+		config := snowy.Config{Middleware: []snowy.Middleware{inspect}}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, res)
+		assert.IsType(t, &snowy.RequestError{}, err)
+	})
+
+	t.Run("runs on every attempt", func(t *testing.T) {
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		calls := 0
+		count := func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls++
+				return next(req)
+			}
+		}
+
+		config := snowy.Config{
+			Retry:      snowy.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			Middleware: []snowy.Middleware{count},
+		}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, res.Attempts)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("rejects middleware without a response or an error", func(t *testing.T) {
+		empty := func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				return nil, nil
+			}
+		}
+		config := snowy.Config{Middleware: []snowy.Middleware{empty}}
+		res, err := snowy.Get[TestResponse](config, "http://example.com", nil, snowy.RequestData{})
+		assert.Nil(t, res)
+		assert.ErrorContains(t, err, "middleware returned neither a response nor an error")
+	})
+
+	t.Run("built-in middleware", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Len(t, r.Header.Get("X-Request-Id"), 32)
+			assert.Equal(t, "snowy-test/1.0", r.Header.Get("User-Agent"))
+			assert.Equal(t, "injected", r.Header.Get("X-Injected"))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		client := snowy.NewClient(ts.URL, snowy.Config{})
+		client.Use(snowy.RequestID("X-Request-Id"), snowy.UserAgent("snowy-test/1.0"))
+		client.Use(snowy.SetHeaders(snowy.Headers{"X-Injected": "injected"}))
+		res, err := snowy.ClientGet[TestResponse](client, "/", nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("request id keeps existing value", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "fixed", r.Header.Get("X-Request-Id"))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Middleware: []snowy.Middleware{snowy.RequestID("X-Request-Id")}}
+		_, err := snowy.Get[TestResponse](config, ts.URL, snowy.Headers{"X-Request-Id": "fixed"}, snowy.RequestData{})
+		assert.Nil(t, err)
+	})
+}
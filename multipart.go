@@ -0,0 +1,183 @@
+package snowy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Multipart is a multipart/form-data request body. Parts are written in the order
+// they were added and streamed to the server as the request is sent, so large files
+// are never buffered in memory.
+//
+//	form := snowy.NewMultipart()
+//	form.AddField("description", "Quarterly report")
+//	form.AddFilePath("file", "./report.pdf")
+//	form.AddJSON("metadata", Metadata{Public: true})
+//
+//	response, err := snowy.Post[UploadResponse](config, "https://api.example.com/uploads", nil, snowy.RequestData{
+//		Multipart: form,
+//	})
+//
+// Files added from a path are opened again for every attempt. Files added from an
+// io.Reader can only be replayed by retries when the reader implements io.Seeker.
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	name        string
+	filename    string
+	contentType string
+	open        func() (io.Reader, error)
+}
+
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(nil).Boundary()}
+}
+
+func (m *Multipart) AddField(name, value string) {
+	m.parts = append(m.parts, multipartPart{
+		name: name,
+		open: func() (io.Reader, error) { return strings.NewReader(value), nil },
+	})
+}
+
+// AddFile adds a file part read from r. An empty content type defaults to
+// application/octet-stream.
+func (m *Multipart) AddFile(name, filename, contentType string, r io.Reader) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	m.parts = append(m.parts, multipartPart{
+		name:        name,
+		filename:    filename,
+		contentType: contentType,
+		open:        replayable(r),
+	})
+}
+
+// AddFilePath adds a file part read from the file at path, guessing its content
+// type from the file extension.
+func (m *Multipart) AddFilePath(name, path string) {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	m.parts = append(m.parts, multipartPart{
+		name:        name,
+		filename:    filepath.Base(path),
+		contentType: contentType,
+		open: func() (io.Reader, error) {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("opening multipart file: %w", err)
+			}
+			return file, nil
+		},
+	})
+}
+
+// AddJSON adds a part holding v encoded as JSON.
+func (m *Multipart) AddJSON(name string, v any) {
+	m.parts = append(m.parts, multipartPart{
+		name:        name,
+		contentType: "application/json",
+		open: func() (io.Reader, error) {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("marshalling multipart JSON part %q: %w", name, err)
+			}
+			return strings.NewReader(string(data)), nil
+		},
+	})
+}
+
+// ContentType returns the multipart/form-data content type, including the boundary.
+func (m *Multipart) ContentType() string {
+	if m.boundary == "" {
+		m.boundary = multipart.NewWriter(nil).Boundary()
+	}
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// reader returns the body of the form. The parts are written from a separate
+// goroutine into a pipe, which stops as soon as the reading side is closed.
+func (m *Multipart) reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w := multipart.NewWriter(pw)
+		w.SetBoundary(m.boundary)
+		for _, part := range m.parts {
+			if err := part.write(w); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(w.Close())
+	}()
+	return pr
+}
+
+func (p multipartPart) write(w *multipart.Writer) error {
+	r, err := p.open()
+	if err != nil {
+		return err
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	header := make(textproto.MIMEHeader)
+	disposition := map[string]string{"name": p.name}
+	if p.filename != "" {
+		disposition["filename"] = p.filename
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", disposition))
+	if p.contentType != "" {
+		header.Set("Content-Type", p.contentType)
+	}
+	dst, err := w.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("writing multipart part %q: %w", p.name, err)
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("writing multipart part %q: %w", p.name, err)
+	}
+	return nil
+}
+
+// replayable returns a function that provides r for every attempt of a request.
+// Seekable readers are rewound to their initial offset, other readers can only be
+// used once.
+func replayable(r io.Reader) func() (io.Reader, error) {
+	if seeker, ok := r.(io.Seeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		return func() (io.Reader, error) {
+			if err != nil {
+				return nil, fmt.Errorf("seeking multipart file: %w", err)
+			}
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("seeking multipart file: %w", err)
+			}
+			return io.NopCloser(r), nil
+		}
+	}
+	var once sync.Once
+	return func() (io.Reader, error) {
+		used := true
+		once.Do(func() { used = false })
+		if used {
+			return nil, fmt.Errorf("multipart file cannot be read again")
+		}
+		return io.NopCloser(r), nil
+	}
+}
@@ -0,0 +1,181 @@
+package snowy_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyMultipart(t *testing.T) {
+	t.Run("fields, files and json parts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.json")
+		assert.Nil(t, os.WriteFile(path, []byte(`{"total":10}`), 0o600))
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary="))
+			reader, err := r.MultipartReader()
+			assert.Nil(t, err)
+
+			part, err := reader.NextPart()
+			assert.Nil(t, err)
+			assert.Equal(t, "description", part.FormName())
+			value, _ := io.ReadAll(part)
+			assert.Equal(t, "quarterly report", string(value))
+
+			part, err = reader.NextPart()
+			assert.Nil(t, err)
+			assert.Equal(t, "avatar", part.FormName())
+			assert.Equal(t, "avatar.png", part.FileName())
+			assert.Equal(t, "image/png", part.Header.Get("Content-Type"))
+			value, _ = io.ReadAll(part)
+			assert.Equal(t, "png-bytes", string(value))
+
+			part, err = reader.NextPart()
+			assert.Nil(t, err)
+			assert.Equal(t, "report", part.FormName())
+			assert.Equal(t, "report.json", part.FileName())
+			assert.Equal(t, "application/json", part.Header.Get("Content-Type"))
+			value, _ = io.ReadAll(part)
+			assert.Equal(t, `{"total":10}`, string(value))
+
+			part, err = reader.NextPart()
+			assert.Nil(t, err)
+			assert.Equal(t, "user", part.FormName())
+			assert.Equal(t, "application/json", part.Header.Get("Content-Type"))
+			var user FakeUser
+			assert.Nil(t, json.NewDecoder(part).Decode(&user))
+			assert.Equal(t, "123", user.ID)
+
+			_, err = reader.NextPart()
+			assert.ErrorIs(t, err, io.EOF)
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer ts.Close()
+
+		form := snowy.NewMultipart()
+		form.AddField("description", "quarterly report")
+		form.AddFile("avatar", "avatar.png", "image/png", strings.NewReader("png-bytes"))
+		form.AddFilePath("report", path)
+		form.AddJSON("user", FakeUser{ID: "123"})
+
+		res, err := snowy.Post[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{Multipart: form})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+	})
+
+	t.Run("streams large files", func(t *testing.T) {
+		const size = 8 << 20
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, int64(-1), r.ContentLength)
+			reader, err := r.MultipartReader()
+			assert.Nil(t, err)
+			part, err := reader.NextPart()
+			assert.Nil(t, err)
+			n, err := io.Copy(io.Discard, part)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(size), n)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		form := snowy.NewMultipart()
+		form.AddFile("file", "large.bin", "", io.LimitReader(zeroReader{}, size))
+		res, err := snowy.Put[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{Multipart: form})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("replays seekable files on retry", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Nil(t, r.ParseMultipartForm(1<<20))
+			file, header, err := r.FormFile("file")
+			assert.Nil(t, err)
+			assert.Equal(t, "data.txt", header.Filename)
+			content, _ := io.ReadAll(file)
+			assert.Equal(t, "content", string(content))
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		form := snowy.NewMultipart()
+		form.AddFile("file", "data.txt", "text/plain", bytes.NewReader([]byte("content")))
+		config := snowy.Config{Retry: snowy.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+		res, err := snowy.Post[TestResponse](config, ts.URL, nil, snowy.RequestData{Multipart: form})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, res.Attempts)
+	})
+
+	t.Run("non seekable files cannot be replayed", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		form := snowy.NewMultipart()
+		form.AddFile("file", "data.txt", "text/plain", io.LimitReader(strings.NewReader("content"), 7))
+		config := snowy.Config{Retry: snowy.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+		res, err := snowy.Post[TestResponse](config, ts.URL, nil, snowy.RequestData{Multipart: form})
+		assert.Nil(t, res)
+		assert.ErrorContains(t, err, "multipart file cannot be read again")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		form := snowy.NewMultipart()
+		form.AddFilePath("file", filepath.Join(t.TempDir(), "missing.txt"))
+		res, err := snowy.Post[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{Multipart: form})
+		assert.Nil(t, res)
+		assert.ErrorContains(t, err, "opening multipart file")
+	})
+
+	t.Run("middleware without a round trip does not leak the writer", func(t *testing.T) {
+		abort := func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("aborted")
+			}
+		}
+		config := snowy.Config{Middleware: []snowy.Middleware{abort}}
+		before := runtime.NumGoroutine()
+		for range 50 {
+			form := snowy.NewMultipart()
+			form.AddField("data", strings.Repeat("x", 100<<10))
+			_, err := snowy.Post[TestResponse](config, "http://example.com", nil, snowy.RequestData{Multipart: form})
+			assert.ErrorContains(t, err, "aborted")
+		}
+		assert.Eventually(t, func() bool {
+			return runtime.NumGoroutine() <= before+5
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,280 @@
+package snowy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is an OAuth2 access token returned by a token endpoint.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Scope        string
+	Expiry       time.Time // Zero when the token does not expire
+}
+
+// valid reports whether the token can still be used for at least delta.
+func (t *Token) valid(delta time.Duration) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry))
+}
+
+// authorization returns the value of the Authorization header for the token.
+func (t *Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	RefreshToken string      `json:"refresh_token"`
+	Scope        string      `json:"scope"`
+	ExpiresIn    json.Number `json:"expires_in"`
+}
+
+// TokenSource fetches OAuth2 access tokens from a token endpoint and caches them
+// until shortly before they expire. Concurrent callers share a single request to
+// the token endpoint. When a refresh token is issued, it is used to renew the
+// access token, falling back to the original grant if the refresh fails.
+//
+//	tokens := snowy.ClientCredentials("https://auth.example.com/oauth/token", "client-id", "client-secret", "read:users")
+//
+//	api := snowy.NewClient("https://api.example.com", snowy.Config{})
+//	api.Use(snowy.OAuth2(tokens))
+type TokenSource struct {
+	TokenURL         string
+	ClientID         string
+	ClientSecret     string
+	Scopes           []string
+	Params           map[string]string // Additional parameters sent to the token endpoint, such as audience
+	SendClientInBody bool              // Send the client credentials as form parameters instead of HTTP Basic authentication
+	ExpiryDelta      time.Duration     // How long before expiry a token is renewed, defaults to 10 seconds
+	Config           Config            // Configuration of the requests to the token endpoint
+
+	grant func(ctx context.Context) (map[string]string, error)
+
+	mu           sync.Mutex
+	token        *Token
+	refreshToken string
+	fetching     *tokenFetch
+}
+
+type tokenFetch struct {
+	done     chan struct{}
+	token    *Token
+	err      error
+	canceled bool // The context of the caller making the request ended
+}
+
+// ClientCredentials returns a TokenSource using the client_credentials grant.
+func ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *TokenSource {
+	return &TokenSource{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		grant: func(context.Context) (map[string]string, error) {
+			return map[string]string{"grant_type": "client_credentials"}, nil
+		},
+	}
+}
+
+// RefreshToken returns a TokenSource using the refresh_token grant. Refresh
+// tokens rotated by the server replace refreshToken.
+func RefreshToken(tokenURL, clientID, clientSecret, refreshToken string) *TokenSource {
+	return &TokenSource{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		refreshToken: refreshToken,
+	}
+}
+
+// JWTBearer returns a TokenSource using the JWT bearer grant (RFC 7523). The
+// assertion function is called for every token request and returns a signed JWT.
+func JWTBearer(tokenURL string, assertion func(ctx context.Context) (string, error), scopes ...string) *TokenSource {
+	return &TokenSource{
+		TokenURL: tokenURL,
+		Scopes:   scopes,
+		grant: func(ctx context.Context) (map[string]string, error) {
+			jwt, err := assertion(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("creating JWT assertion: %w", err)
+			}
+			return map[string]string{
+				"grant_type": "urn:ietf:params:oauth:grant-type:jwt-bearer",
+				"assertion":  jwt,
+			}, nil
+		},
+	}
+}
+
+// Token returns a valid access token, fetching a new one when needed.
+func (s *TokenSource) Token(ctx context.Context) (*Token, error) {
+	return s.get(ctx, nil)
+}
+
+// get returns a valid token other than rejected, which the server refused. If
+// another caller already replaced the rejected token, the replacement is returned.
+// Concurrent callers share a single request, made with the context of the first
+// one; when that context ends, the others make the request again with theirs.
+func (s *TokenSource) get(ctx context.Context, rejected *Token) (*Token, error) {
+	for {
+		token, retry, err := s.getOnce(ctx, rejected)
+		if !retry {
+			return token, err
+		}
+	}
+}
+
+func (s *TokenSource) getOnce(ctx context.Context, rejected *Token) (*Token, bool, error) {
+	delta := s.ExpiryDelta
+	if delta == 0 {
+		delta = 10 * time.Second
+	}
+
+	s.mu.Lock()
+	if s.token != rejected && s.token.valid(delta) {
+		token := s.token
+		s.mu.Unlock()
+		return token, false, nil
+	}
+	f := s.fetching
+	leader := f == nil
+	if leader {
+		f = &tokenFetch{done: make(chan struct{})}
+		s.fetching = f
+	}
+	s.mu.Unlock()
+
+	if leader {
+		f.token, f.err = s.fetch(ctx)
+		f.canceled = f.err != nil && ctx.Err() != nil
+		s.mu.Lock()
+		if f.err == nil {
+			s.token = f.token
+		}
+		s.fetching = nil
+		s.mu.Unlock()
+		close(f.done)
+	}
+
+	select {
+	case <-f.done:
+		return f.token, !leader && f.canceled && ctx.Err() == nil, f.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+func (s *TokenSource) fetch(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	refreshToken := s.refreshToken
+	s.mu.Unlock()
+
+	if refreshToken != "" {
+		token, err := s.request(ctx, map[string]string{"grant_type": "refresh_token", "refresh_token": refreshToken})
+		if err == nil || s.grant == nil {
+			return token, err
+		}
+	}
+	if s.grant == nil {
+		return nil, errors.New("fetching oauth2 token: no grant or refresh token available")
+	}
+	params, err := s.grant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.request(ctx, params)
+}
+
+func (s *TokenSource) request(ctx context.Context, params map[string]string) (*Token, error) {
+	form := make(map[string]string, len(params)+len(s.Params)+3)
+	for k, v := range s.Params {
+		form[k] = v
+	}
+	for k, v := range params {
+		form[k] = v
+	}
+	if len(s.Scopes) > 0 && params["grant_type"] != "refresh_token" {
+		form["scope"] = strings.Join(s.Scopes, " ")
+	}
+	headers := Headers{}
+	if s.SendClientInBody || s.ClientSecret == "" {
+		if s.ClientID != "" {
+			form["client_id"] = s.ClientID
+		}
+		if s.ClientSecret != "" {
+			form["client_secret"] = s.ClientSecret
+		}
+	} else {
+		headers.AddBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+	}
+
+	requestTime := time.Now()
+	res, err := PostCtx[tokenResponse](ctx, s.Config, s.TokenURL, headers, RequestData{FormData: form})
+	if err != nil {
+		return nil, fmt.Errorf("fetching oauth2 token: %w", err)
+	}
+	if res.Data == nil || res.Data.AccessToken == "" {
+		return nil, errors.New("fetching oauth2 token: response has no access_token")
+	}
+
+	token := &Token{
+		AccessToken:  res.Data.AccessToken,
+		TokenType:    res.Data.TokenType,
+		RefreshToken: res.Data.RefreshToken,
+		Scope:        res.Data.Scope,
+	}
+	if seconds, err := res.Data.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.Expiry = requestTime.Add(time.Duration(seconds) * time.Second)
+	}
+	if token.RefreshToken != "" {
+		s.mu.Lock()
+		s.refreshToken = token.RefreshToken
+		s.mu.Unlock()
+	}
+	return token, nil
+}
+
+// OAuth2 sets the Authorization header of every request to an access token from
+// source. When the server answers 401 Unauthorized, a new token is fetched and the
+// request is sent once more, provided its body can be replayed.
+func OAuth2(source *TokenSource) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			token, err := source.get(req.Context(), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", token.authorization())
+			res, err := next(req)
+			if res == nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+
+			replay, ok := replayRequest(req)
+			if !ok {
+				return res, err
+			}
+			token, tokenErr := source.get(req.Context(), token)
+			if tokenErr != nil {
+				return res, err
+			}
+			res.Body.Close()
+			replay.Header.Set("Authorization", token.authorization())
+			return next(replay)
+		}
+	}
+}
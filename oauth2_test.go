@@ -0,0 +1,242 @@
+package snowy_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyOAuth2(t *testing.T) {
+	t.Run("client credentials", func(t *testing.T) {
+		var issued atomic.Int32
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			issued.Add(1)
+			assert.Equal(t, http.MethodPost, r.Method)
+			id, secret, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "client", id)
+			assert.Equal(t, "secret", secret)
+			assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+			assert.Equal(t, "read write", r.FormValue("scope"))
+			assert.Equal(t, "https://api.example.com", r.FormValue("audience"))
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"token-1","token_type":"bearer","expires_in":3600}`)
+		}))
+		defer tokens.Close()
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer api.Close()
+
+		source := snowy.ClientCredentials(tokens.URL, "client", "secret", "read", "write")
+		source.Params = map[string]string{"audience": "https://api.example.com"}
+		client := snowy.NewClient(api.URL, snowy.Config{})
+		client.Use(snowy.OAuth2(source))
+		for range 3 {
+			_, err := snowy.ClientGet[TestResponse](client, "/", nil, snowy.RequestData{})
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, int32(1), issued.Load())
+	})
+
+	t.Run("deduplicates concurrent refreshes", func(t *testing.T) {
+		var issued atomic.Int32
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := issued.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("token-%d", n), "expires_in": 3600})
+		}))
+		defer tokens.Close()
+
+		source := snowy.ClientCredentials(tokens.URL, "client", "secret")
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := source.Token(context.Background())
+				assert.Nil(t, err)
+				assert.Equal(t, "token-1", token.AccessToken)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), issued.Load())
+	})
+
+	t.Run("waiters outlive a canceled leader", func(t *testing.T) {
+		var issued atomic.Int32
+		started, release := make(chan struct{}), make(chan struct{})
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := issued.Add(1)
+			if n == 1 {
+				close(started)
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("token-%d", n), "expires_in": 3600})
+		}))
+		defer tokens.Close()
+		defer close(release)
+
+		source := snowy.ClientCredentials(tokens.URL, "client", "secret")
+		leader := make(chan error)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := source.Token(ctx)
+			leader <- err
+		}()
+		<-started
+		token, err := source.Token(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "token-2", token.AccessToken)
+		assert.ErrorIs(t, <-leader, context.DeadlineExceeded)
+	})
+
+	t.Run("renews tokens before they expire", func(t *testing.T) {
+		var grants []string
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			grants = append(grants, r.FormValue("grant_type")+" "+r.FormValue("refresh_token"))
+			n := len(grants)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  fmt.Sprintf("token-%d", n),
+				"expires_in":    5,
+				"refresh_token": fmt.Sprintf("refresh-%d", n),
+			})
+		}))
+		defer tokens.Close()
+
+		source := snowy.ClientCredentials(tokens.URL, "client", "secret")
+		first, err := source.Token(context.Background())
+		assert.Nil(t, err)
+		second, err := source.Token(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "token-1", first.AccessToken)
+		assert.Equal(t, "token-2", second.AccessToken)
+		assert.Equal(t, []string{"client_credentials ", "refresh_token refresh-1"}, grants)
+	})
+
+	t.Run("refresh token grant rotates refresh tokens", func(t *testing.T) {
+		var refreshTokens []string
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "refresh_token", r.FormValue("grant_type"))
+			assert.Equal(t, "client", r.FormValue("client_id"))
+			refreshTokens = append(refreshTokens, r.FormValue("refresh_token"))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  "token",
+				"expires_in":    1,
+				"refresh_token": fmt.Sprintf("refresh-%d", len(refreshTokens)),
+			})
+		}))
+		defer tokens.Close()
+
+		source := snowy.RefreshToken(tokens.URL, "client", "", "initial")
+		source.Token(context.Background())
+		source.Token(context.Background())
+		assert.Equal(t, []string{"initial", "refresh-1"}, refreshTokens)
+	})
+
+	t.Run("jwt bearer", func(t *testing.T) {
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.FormValue("grant_type"))
+			assert.Equal(t, "signed.jwt.assertion", r.FormValue("assertion"))
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"token-1","token_type":"bearer","expires_in":3600}`)
+		}))
+		defer tokens.Close()
+
+		source := snowy.JWTBearer(tokens.URL, func(ctx context.Context) (string, error) {
+			return "signed.jwt.assertion", nil
+		})
+		token, err := source.Token(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "token-1", token.AccessToken)
+		assert.False(t, token.Expiry.IsZero())
+	})
+
+	t.Run("refreshes once on unauthorized and replays", func(t *testing.T) {
+		var issued atomic.Int32
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := issued.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("token-%d", n), "expires_in": 3600})
+		}))
+		defer tokens.Close()
+		var calls atomic.Int32
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"id":"1","username":"snowy","email":""}`, string(body))
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer api.Close()
+
+		config := snowy.Config{Middleware: []snowy.Middleware{snowy.OAuth2(snowy.ClientCredentials(tokens.URL, "client", "secret"))}}
+		res, err := snowy.Post[TestResponse](config, api.URL, nil, snowy.RequestData{
+			JsonData: FakeUser{ID: "1", Username: "snowy"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, int32(2), issued.Load())
+	})
+
+	t.Run("gives up after one refresh", func(t *testing.T) {
+		var issued atomic.Int32
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := issued.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("token-%d", n), "expires_in": 3600})
+		}))
+		defer tokens.Close()
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer api.Close()
+
+		config := snowy.Config{Middleware: []snowy.Middleware{snowy.OAuth2(snowy.ClientCredentials(tokens.URL, "client", "secret"))}}
+		_, err := snowy.Get[TestResponse](config, api.URL, nil, snowy.RequestData{})
+		var reqErr *snowy.RequestError
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, http.StatusUnauthorized, reqErr.StatusCode)
+		assert.Equal(t, int32(2), issued.Load())
+	})
+
+	t.Run("token endpoint errors", func(t *testing.T) {
+		tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_client"}`)
+		}))
+		defer tokens.Close()
+
+		_, err := snowy.ClientCredentials(tokens.URL, "client", "wrong").Token(context.Background())
+		var reqErr *snowy.RequestError
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, map[string]any{"error": "invalid_client"}, reqErr.Response)
+		assert.ErrorContains(t, err, "fetching oauth2 token")
+	})
+}
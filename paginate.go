@@ -0,0 +1,269 @@
+package snowy
+
+import (
+	"iter"
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PageRequest is the request for the next page. NextPage functions update it
+// in place to point to the following page.
+type PageRequest struct {
+	URL   string
+	Query map[string]string
+}
+
+// NextPage moves req to the page following res and reports whether there is one.
+// It is called with a nil res before the first page is requested, so it can set up
+// the initial query; items is the number of items decoded from res.
+type NextPage[P any] func(req *PageRequest, res *Response[P], items int) bool
+
+// Pager describes how to walk a paginated endpoint. P is the type of a page and
+// T the type of the items extracted from it by Items.
+type Pager[P, T any] struct {
+	Items    func(page *P) []T
+	Next     NextPage[P]
+	MaxPages int // Stop after this many pages, zero means no limit
+	MaxItems int // Stop after this many items, zero means no limit
+}
+
+// Paginate requests pages with Get until the pager runs out of pages and yields
+// every item on them. The iteration stops at the first error, which is yielded
+// with the zero value of T:
+//
+//	pager := snowy.Pager[[]UserResponse, UserResponse]{
+//		Items: func(page *[]UserResponse) []UserResponse { return *page },
+//		Next:  snowy.NextLink[[]UserResponse](),
+//	}
+//
+//	for user, err := range snowy.Paginate(config, "https://api.example.com/users", nil, snowy.RequestData{}, pager) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(user.Name)
+//	}
+func Paginate[P, T any](config Config, url string, headers map[string]string, query RequestData, pager Pager[P, T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		// The path parameters and query.Query are encoded in the first URL only, so
+		// the pages linked by the server are requested as they are.
+		start, err := buildURL(url, RequestData{Query: query.Query, PathParams: query.PathParams})
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		req := &PageRequest{URL: start, Query: maps.Clone(query.QueryParams)}
+		if req.Query == nil {
+			req.Query = make(map[string]string)
+		}
+		if !pager.Next(req, nil, 0) {
+			return
+		}
+		items := 0
+		for pages := 0; pager.MaxPages == 0 || pages < pager.MaxPages; pages++ {
+			if config.Ctx != nil {
+				if err := config.Ctx.Err(); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+			page := query
+			page.Query = nil
+			page.PathParams = nil
+			page.QueryParams = req.Query
+			res, err := Get[P](config, req.URL, maps.Clone(headers), page)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			var found []T
+			if res.Data != nil {
+				found = pager.Items(res.Data)
+			}
+			for _, item := range found {
+				if !yield(item, nil) {
+					return
+				}
+				items++
+				if pager.MaxItems > 0 && items >= pager.MaxItems {
+					return
+				}
+			}
+			if !pager.Next(req, res, len(found)) {
+				return
+			}
+		}
+	}
+}
+
+// NextLink follows the RFC 8288 Link header with rel="next".
+func NextLink[P any]() NextPage[P] {
+	return func(req *PageRequest, res *Response[P], items int) bool {
+		if res == nil {
+			return true
+		}
+		for _, link := range parseLinks(res.Headers.Values("Link")) {
+			if !link.hasRel("next") {
+				continue
+			}
+			base, err := url.Parse(req.URL)
+			if err != nil {
+				return false
+			}
+			next, err := base.Parse(link.target)
+			if err != nil {
+				return false
+			}
+			req.URL = next.String()
+			clear(req.Query)
+			return true
+		}
+		return false
+	}
+}
+
+// NextCursor sends the cursor extracted from each page in the param query
+// parameter. The iteration stops when the cursor is empty.
+func NextCursor[P any](param string, cursor func(page *P) string) NextPage[P] {
+	return func(req *PageRequest, res *Response[P], items int) bool {
+		if res == nil {
+			return true
+		}
+		if res.Data == nil {
+			return false
+		}
+		next := cursor(res.Data)
+		if next == "" {
+			return false
+		}
+		req.Query[param] = next
+		return true
+	}
+}
+
+// NextOffset requests limit items per page using offset and limit query
+// parameters. The iteration stops at the first page with fewer than limit items.
+func NextOffset[P any](offsetParam, limitParam string, limit int) NextPage[P] {
+	return func(req *PageRequest, res *Response[P], items int) bool {
+		if res == nil {
+			if _, ok := req.Query[offsetParam]; !ok {
+				req.Query[offsetParam] = "0"
+			}
+			req.Query[limitParam] = strconv.Itoa(limit)
+			return true
+		}
+		if items < limit {
+			return false
+		}
+		offset, _ := strconv.Atoi(req.Query[offsetParam])
+		req.Query[offsetParam] = strconv.Itoa(offset + items)
+		return true
+	}
+}
+
+// NextPageNumber increments the param query parameter, starting at first.
+// The iteration stops at the first empty page.
+func NextPageNumber[P any](param string, first int) NextPage[P] {
+	return func(req *PageRequest, res *Response[P], items int) bool {
+		if res == nil {
+			if _, ok := req.Query[param]; !ok {
+				req.Query[param] = strconv.Itoa(first)
+			}
+			return true
+		}
+		if items == 0 {
+			return false
+		}
+		page, err := strconv.Atoi(req.Query[param])
+		if err != nil {
+			return false
+		}
+		req.Query[param] = strconv.Itoa(page + 1)
+		return true
+	}
+}
+
+type link struct {
+	target string
+	params map[string]string
+}
+
+func (l link) hasRel(rel string) bool {
+	for _, value := range strings.Fields(l.params["rel"]) {
+		if strings.EqualFold(value, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinks parses the values of RFC 8288 Link headers, each of which may hold
+// several comma separated links such as `<https://host/page/2>; rel="next"`.
+func parseLinks(values []string) []link {
+	var links []link
+	for _, value := range values {
+		for value != "" {
+			value = strings.TrimLeft(value, " \t,")
+			if !strings.HasPrefix(value, "<") {
+				break
+			}
+			end := strings.IndexByte(value, '>')
+			if end < 0 {
+				break
+			}
+			l := link{target: value[1:end], params: make(map[string]string)}
+			value = value[end+1:]
+			for {
+				value = strings.TrimLeft(value, " \t")
+				if !strings.HasPrefix(value, ";") {
+					break
+				}
+				value = value[1:]
+				end := strings.IndexAny(value, "=;,")
+				if end < 0 {
+					end = len(value)
+				}
+				name := strings.ToLower(strings.TrimSpace(value[:end]))
+				var param string
+				if value = value[end:]; strings.HasPrefix(value, "=") {
+					param, value = parseLinkParam(value[1:])
+				}
+				if _, ok := l.params[name]; !ok {
+					l.params[name] = param
+				}
+			}
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// parseLinkParam reads a token or quoted string parameter value and returns it
+// together with the remaining input.
+func parseLinkParam(value string) (string, string) {
+	value = strings.TrimLeft(value, " \t")
+	if !strings.HasPrefix(value, `"`) {
+		end := strings.IndexAny(value, ";,")
+		if end < 0 {
+			return strings.TrimSpace(value), ""
+		}
+		return strings.TrimSpace(value[:end]), value[end:]
+	}
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if i+1 < len(value) {
+				i++
+				b.WriteByte(value[i])
+			}
+		case '"':
+			return b.String(), value[i+1:]
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String(), ""
+}
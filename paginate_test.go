@@ -0,0 +1,193 @@
+package snowy_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUsersPage struct {
+	Users      []FakeUser `json:"users"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+func fakeUsers(from, to int) []FakeUser {
+	users := []FakeUser{}
+	for i := from; i < to; i++ {
+		users = append(users, FakeUser{ID: strconv.Itoa(i)})
+	}
+	return users
+}
+
+func collectIDs(t *testing.T, seq func(func(FakeUser, error) bool)) []string {
+	var ids []string
+	for user, err := range seq {
+		assert.Nil(t, err)
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestSnowyPaginate(t *testing.T) {
+	usersPage := func(page *[]FakeUser) []FakeUser { return *page }
+
+	t.Run("link header", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "active", r.URL.Query().Get("status"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page < 2 {
+				w.Header().Add("Link", `</first>; rel="first"`)
+				w.Header().Add("Link", fmt.Sprintf(`</users?status=active&page=%d>; title="next; page"; rel="last next", </prev>; rel=prev`, page+1))
+			}
+			json.NewEncoder(w).Encode(fakeUsers(page*2, page*2+2))
+		}))
+		defer ts.Close()
+
+		pager := snowy.Pager[[]FakeUser, FakeUser]{
+			Items: usersPage,
+			Next:  snowy.NextLink[[]FakeUser](),
+		}
+		seq := snowy.Paginate(snowy.Config{}, ts.URL+"/users", nil, snowy.RequestData{
+			QueryParams: map[string]string{"status": "active"},
+		}, pager)
+		assert.Equal(t, []string{"0", "1", "2", "3", "4", "5"}, collectIDs(t, seq))
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("cursor") {
+			case "":
+				json.NewEncoder(w).Encode(fakeUsersPage{Users: fakeUsers(0, 2), NextCursor: "abc"})
+			case "abc":
+				json.NewEncoder(w).Encode(fakeUsersPage{Users: fakeUsers(2, 3)})
+			default:
+				t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+			}
+		}))
+		defer ts.Close()
+
+		pager := snowy.Pager[fakeUsersPage, FakeUser]{
+			Items: func(page *fakeUsersPage) []FakeUser { return page.Users },
+			Next: snowy.NextCursor("cursor", func(page *fakeUsersPage) string {
+				return page.NextCursor
+			}),
+		}
+		seq := snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{}, pager)
+		assert.Equal(t, []string{"0", "1", "2"}, collectIDs(t, seq))
+	})
+
+	t.Run("offset and limit", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			assert.Equal(t, 2, limit)
+			json.NewEncoder(w).Encode(fakeUsers(offset, min(offset+limit, 5)))
+		}))
+		defer ts.Close()
+
+		pager := snowy.Pager[[]FakeUser, FakeUser]{
+			Items: usersPage,
+			Next:  snowy.NextOffset[[]FakeUser]("offset", "limit", 2),
+		}
+		seq := snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{}, pager)
+		assert.Equal(t, []string{"0", "1", "2", "3", "4"}, collectIDs(t, seq))
+	})
+
+	t.Run("page number with limits", func(t *testing.T) {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			json.NewEncoder(w).Encode(fakeUsers(page*10, page*10+3))
+		}))
+		defer ts.Close()
+
+		pager := snowy.Pager[[]FakeUser, FakeUser]{
+			Items:    usersPage,
+			Next:     snowy.NextPageNumber[[]FakeUser]("page", 1),
+			MaxPages: 2,
+		}
+		seq := snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{}, pager)
+		assert.Equal(t, []string{"10", "11", "12", "20", "21", "22"}, collectIDs(t, seq))
+		assert.Equal(t, 2, requests)
+
+		requests = 0
+		pager.MaxItems = 4
+		seq = snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{}, pager)
+		assert.Equal(t, []string{"10", "11", "12", "20"}, collectIDs(t, seq))
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("stops at empty page", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			json.NewEncoder(w).Encode(fakeUsers(page, min(page+1, 2)))
+		}))
+		defer ts.Close()
+
+		pager := snowy.Pager[[]FakeUser, FakeUser]{
+			Items: usersPage,
+			Next:  snowy.NextPageNumber[[]FakeUser]("page", 0),
+		}
+		seq := snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{}, pager)
+		assert.Equal(t, []string{"0", "1"}, collectIDs(t, seq))
+	})
+
+	t.Run("yields errors", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(fakeUsers(0, 1))
+		}))
+		defer ts.Close()
+
+		pager := snowy.Pager[[]FakeUser, FakeUser]{
+			Items: usersPage,
+			Next:  snowy.NextPageNumber[[]FakeUser]("page", 1),
+		}
+		var errs []error
+		for _, err := range snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{}, pager) {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		assert.Len(t, errs, 1)
+		assert.IsType(t, &snowy.RequestError{}, errs[0])
+	})
+
+	t.Run("context cancellation", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(fakeUsers(0, 1))
+		}))
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		pager := snowy.Pager[[]FakeUser, FakeUser]{
+			Items: usersPage,
+			Next:  snowy.NextPageNumber[[]FakeUser]("page", 1),
+		}
+		items := 0
+		var lastErr error
+		for _, err := range snowy.Paginate(snowy.Config{Ctx: ctx}, ts.URL, nil, snowy.RequestData{}, pager) {
+			if err != nil {
+				lastErr = err
+				break
+			}
+			items++
+			cancel()
+		}
+		assert.Equal(t, 1, items)
+		assert.ErrorIs(t, lastErr, context.Canceled)
+	})
+}
@@ -0,0 +1,259 @@
+package snowy_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tunnel copies data between both connections until one of them is closed.
+func tunnel(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// serveHTTPProxy is a stand-in forward proxy requiring user:pass. Plain requests
+// are answered by the proxy itself, and CONNECT requests are tunneled.
+func serveHTTPProxy(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		if r.Method != http.MethodConnect {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"proxied ` + r.URL.String() + `"}`))
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if !assert.Nil(t, err) {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, buf.Reader.Buffered())
+		tunnel(conn, target)
+	}))
+	return ts, &requests
+}
+
+// serveSOCKS5 is a stand-in SOCKS5 proxy requiring the user:pass credentials.
+func serveSOCKS5(t *testing.T) (net.Listener, *atomic.Int32) {
+	var connects atomic.Int32
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				read := func(n int) []byte {
+					b := make([]byte, n)
+					io.ReadFull(conn, b)
+					return b
+				}
+				// Greeting, selecting the username/password method.
+				header := read(2)
+				if !bytes.Contains(read(int(header[1])), []byte{0x02}) {
+					conn.Write([]byte{0x05, 0xff})
+					return
+				}
+				conn.Write([]byte{0x05, 0x02})
+				auth := read(2)
+				user := read(int(auth[1]))
+				pass := read(int(read(1)[0]))
+				if string(user) != "user" || string(pass) != "pass" {
+					conn.Write([]byte{0x01, 0x01})
+					return
+				}
+				conn.Write([]byte{0x01, 0x00})
+
+				request := read(4)
+				var host string
+				switch request[3] {
+				case 0x01:
+					host = net.IP(read(4)).String()
+				case 0x03:
+					host = string(read(int(read(1)[0])))
+				case 0x04:
+					host = net.IP(read(16)).String()
+				}
+				port := binary.BigEndian.Uint16(read(2))
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+				if err != nil {
+					conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				connects.Add(1)
+				conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+				tunnel(conn, target)
+			}()
+		}
+	}()
+	return ln, &connects
+}
+
+func TestSnowyProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"direct"}`))
+	}))
+	defer target.Close()
+	proxy, requests := serveHTTPProxy(t)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+
+	t.Run("http proxy with credentials", func(t *testing.T) {
+		config := snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: proxyURL.String()}}
+		res, err := snowy.Get[TestResponse](config, "http://api.example.test/users", nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "proxied http://api.example.test/users", res.Data.Message)
+
+		config = snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: proxy.URL}}
+		_, err = snowy.Get[TestResponse](config, "http://api.example.test/users", nil, snowy.RequestData{})
+		var reqErr *snowy.RequestError
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, http.StatusProxyAuthRequired, reqErr.StatusCode)
+	})
+
+	t.Run("https through CONNECT", func(t *testing.T) {
+		secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"tunneled"}`))
+		}))
+		defer secure.Close()
+		roots := x509.NewCertPool()
+		roots.AddCert(secure.Certificate())
+
+		before := requests.Load()
+		config := snowy.Config{
+			TLS:   &snowy.TLSConfig{RootCAs: roots},
+			Proxy: &snowy.ProxyConfig{HTTPS: proxyURL.String()},
+		}
+		res, err := snowy.Get[TestResponse](config, secure.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "tunneled", res.Data.Message)
+		assert.Equal(t, before+1, requests.Load())
+
+		// HTTPS defaults to the HTTP proxy of an explicit configuration.
+		config.Proxy = &snowy.ProxyConfig{HTTP: proxyURL.String()}
+		res, err = snowy.Get[TestResponse](config, secure.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "tunneled", res.Data.Message)
+		assert.Equal(t, before+2, requests.Load())
+	})
+
+	t.Run("socks5 proxy with credentials", func(t *testing.T) {
+		socks, connects := serveSOCKS5(t)
+		defer socks.Close()
+
+		config := snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: "socks5://user:pass@" + socks.Addr().String()}}
+		res, err := snowy.Get[TestResponse](config, target.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "direct", res.Data.Message)
+		assert.Equal(t, int32(1), connects.Load())
+
+		config = snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: "socks5h://user:pass@" + socks.Addr().String()}}
+		res, err = snowy.Get[TestResponse](config, target.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "direct", res.Data.Message)
+		assert.Equal(t, int32(2), connects.Load())
+
+		config = snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: "socks5://user:wrong@" + socks.Addr().String()}}
+		_, err = snowy.Get[TestResponse](config, target.URL, nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+		assert.Equal(t, int32(2), connects.Load())
+	})
+
+	t.Run("no proxy rules", func(t *testing.T) {
+		port := target.URL[len("http://127.0.0.1:"):]
+		tests := []struct {
+			url     string
+			noProxy string
+			want    string
+		}{
+			{target.URL, "", "proxied " + target.URL + "/"},
+			{target.URL, "127.0.0.1", "direct"},
+			{target.URL, "example.test, 127.0.0.0/8", "direct"},
+			{target.URL, "127.0.0.1:" + port, "direct"},
+			{target.URL, "127.0.0.1:1", "proxied " + target.URL + "/"},
+			{target.URL, "*", "direct"},
+			{"http://localhost:" + port, "LOCALHOST", "direct"},
+			{"http://api.example.test", "example.test", ""},
+			{"http://api.example.test", ".api.example.test", "proxied http://api.example.test/"},
+			{"http://api.example.test", "other.test,ample.test", "proxied http://api.example.test/"},
+		}
+		for _, tt := range tests {
+			config := snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: proxyURL.String(), NoProxy: tt.noProxy}}
+			before := requests.Load()
+			res, err := snowy.Get[TestResponse](config, tt.url+"/", nil, snowy.RequestData{})
+			if tt.want == "" {
+				// Not proxied, and the host does not resolve.
+				assert.NotNil(t, err, tt.noProxy)
+				assert.Equal(t, before, requests.Load(), tt.noProxy)
+				continue
+			}
+			if assert.Nil(t, err, tt.noProxy) {
+				assert.Equal(t, tt.want, res.Data.Message, tt.noProxy)
+			}
+		}
+	})
+
+	t.Run("environment fallback", func(t *testing.T) {
+		t.Setenv("HTTP_PROXY", proxyURL.String())
+		t.Setenv("NO_PROXY", "internal.test")
+		env := snowy.ProxyFromEnvironment()
+		assert.Equal(t, proxyURL.String(), env.HTTP)
+		assert.Equal(t, "internal.test", env.NoProxy)
+
+		res, err := snowy.Get[TestResponse](snowy.Config{}, "http://api.example.test/", nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "proxied http://api.example.test/", res.Data.Message)
+
+		// Loopback addresses are never proxied from the environment.
+		res, err = snowy.Get[TestResponse](snowy.Config{}, target.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "direct", res.Data.Message)
+
+		// As in net/http, HTTP_PROXY is not used for https:// requests.
+		before := requests.Load()
+		_, err = snowy.Get[TestResponse](snowy.Config{}, "https://api.example.test/", nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+		assert.Equal(t, before, requests.Load())
+
+		// An explicit configuration replaces the environment.
+		_, err = snowy.Get[TestResponse](snowy.Config{Proxy: &snowy.ProxyConfig{}}, "http://api.example.test/", nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+		assert.Equal(t, before, requests.Load())
+	})
+
+	t.Run("invalid proxy", func(t *testing.T) {
+		config := snowy.Config{Proxy: &snowy.ProxyConfig{HTTP: "ftp://proxy.test"}}
+		_, err := snowy.Get[TestResponse](config, target.URL, nil, snowy.RequestData{})
+		assert.ErrorContains(t, err, "unsupported scheme")
+	})
+}
@@ -0,0 +1,312 @@
+package snowy
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeQuery encodes v as query parameters. v is either url.Values, a
+// map[string]string, or a struct, or pointer to one, whose exported fields are
+// encoded according to their query tag:
+//
+//	type ListUsers struct {
+//		Search  string            `query:"search,omitempty"`
+//		Tags    []string          `query:"tag"`                             // tag=a&tag=b
+//		IDs     []int             `query:"ids,comma"`                       // ids=1,2,3
+//		Roles   []string          `query:"roles,brackets"`                  // roles[]=admin&roles[]=owner
+//		Since   time.Time         `query:"since,omitempty" layout:"2006-01-02"`
+//		Before  time.Time         `query:"before,unix"`                     // Also unixmilli, RFC 3339 by default
+//		Filter  Filter            `query:"filter"`                          // filter[status]=active
+//		Labels  map[string]string `query:"label"`                           // label[env]=prod
+//		Session uuid.UUID         `query:"session"`                         // encoding.TextMarshaler
+//		Secret  string            `query:"-"`
+//	}
+//
+// Untagged fields use the field name, and the fields of embedded structs are
+// encoded as if they were fields of the outer struct. Nil pointers are omitted, as
+// are zero values and empty slices and maps of fields tagged omitempty.
+func EncodeQuery(v any) (url.Values, error) {
+	values := url.Values{}
+	switch v := v.(type) {
+	case nil:
+		return values, nil
+	case url.Values:
+		for k, vs := range v {
+			values[k] = slices.Clone(vs)
+		}
+		return values, nil
+	case map[string]string:
+		for k, value := range v {
+			values.Set(k, value)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("encoding query: unsupported type %T", v)
+	}
+	if err := encodeQueryStruct(values, "", rv); err != nil {
+		return nil, fmt.Errorf("encoding query: %w", err)
+	}
+	return values, nil
+}
+
+type queryField struct {
+	name      string
+	omitempty bool
+	comma     bool   // Join slice items with commas
+	brackets  bool   // Repeat slice items as name[]
+	unix      bool   // Encode times as Unix seconds
+	unixMilli bool   // Encode times as Unix milliseconds
+	layout    string // Encode times with this layout instead of RFC 3339
+}
+
+// encodeQueryStruct encodes the fields of rv, nested below prefix when it is set.
+func encodeQueryStruct(values url.Values, prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("query")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+
+		if sf.Anonymous && name == "" {
+			embedded := fv
+			for embedded.Kind() == reflect.Pointer && !embedded.IsNil() {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Pointer {
+				continue
+			}
+			if embedded.Kind() == reflect.Struct && !isQueryScalar(embedded.Type()) {
+				if err := encodeQueryStruct(values, prefix, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if prefix != "" {
+			name = prefix + "[" + name + "]"
+		}
+		field := queryField{name: name, layout: sf.Tag.Get("layout")}
+		for _, option := range strings.Split(options, ",") {
+			switch option {
+			case "omitempty":
+				field.omitempty = true
+			case "comma":
+				field.comma = true
+			case "brackets":
+				field.brackets = true
+			case "unix":
+				field.unix = true
+			case "unixmilli":
+				field.unixMilli = true
+			}
+		}
+		if err := field.encode(values, fv); err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f queryField) encode(values url.Values, v reflect.Value) error {
+	if f.omitempty && isEmptyQueryValue(v) {
+		return nil
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if s, ok, err := f.scalar(v); ok || err != nil {
+		if err != nil {
+			return err
+		}
+		values.Add(f.name, s)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			item := v.Index(i)
+			for (item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface) && !item.IsNil() {
+				item = item.Elem()
+			}
+			if item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+				continue
+			}
+			s, ok, err := f.scalar(item)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("unsupported slice element type %s", item.Type())
+			}
+			items = append(items, s)
+		}
+		switch {
+		case f.comma:
+			if len(items) > 0 {
+				values.Add(f.name, strings.Join(items, ","))
+			}
+		case f.brackets:
+			for _, item := range items {
+				values.Add(f.name+"[]", item)
+			}
+		default:
+			for _, item := range items {
+				values.Add(f.name, item)
+			}
+		}
+	case reflect.Struct:
+		return encodeQueryStruct(values, f.name, v)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, key := range keys {
+			entry := f
+			entry.name = f.name + "[" + key.String() + "]"
+			entry.omitempty = false
+			if err := entry.encode(values, v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// isQueryScalar reports whether values of type t are encoded as a single value.
+func isQueryScalar(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// scalar formats v as a single value, and reports false when v is a slice, map or
+// struct to be expanded into several parameters.
+func (f queryField) scalar(v reflect.Value) (string, bool, error) {
+	if !v.IsValid() {
+		return "", false, nil
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		switch {
+		case f.unix:
+			return strconv.FormatInt(t.Unix(), 10), true, nil
+		case f.unixMilli:
+			return strconv.FormatInt(t.UnixMilli(), 10), true, nil
+		case f.layout != "":
+			return t.Format(f.layout), true, nil
+		}
+		return t.Format(time.RFC3339), true, nil
+	}
+	if isQueryScalar(v.Type()) {
+		if !v.Type().Implements(textMarshalerType) {
+			// The method has a pointer receiver, and v may not be addressable.
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			v = ptr
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", true, err
+		}
+		return string(text), true, nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), true, nil
+	}
+	return "", false, nil
+}
+
+func isEmptyQueryValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// parseQueryParams adds the query parameters of data to rawURL. They replace the
+// parameters of the same name already in the URL, and QueryParams take precedence
+// over Query. The rest of the URL query is kept as it was written, and the new
+// parameters are appended sorted by name.
+func parseQueryParams(rawURL string, data RequestData) (string, error) {
+	if len(data.QueryParams) == 0 && data.Query == nil {
+		return rawURL, nil
+	}
+	values, err := EncodeQuery(data.Query)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range data.QueryParams {
+		values.Set(k, v)
+	}
+	if len(values) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing request URL: %w", err)
+	}
+	var pairs []string
+	if u.RawQuery != "" {
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			key, _, _ := strings.Cut(pair, "=")
+			if name, err := url.QueryUnescape(key); err == nil {
+				if _, replaced := values[name]; replaced {
+					continue
+				}
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+	u.RawQuery = strings.Join(append(pairs, values.Encode()), "&")
+	return u.String(), nil
+}
@@ -0,0 +1,192 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Level int
+
+func (l *Level) MarshalText() ([]byte, error) {
+	if *l < 0 {
+		return nil, errors.New("negative level")
+	}
+	return []byte(strings.Repeat("*", int(*l))), nil
+}
+
+type UserFilter struct {
+	Status string `query:"status,omitempty"`
+	Admin  *bool  `query:"admin"`
+}
+
+type Paging struct {
+	Limit int `query:"limit,omitempty"`
+}
+
+type ListUsers struct {
+	Paging
+	Search  string            `query:"search,omitempty"`
+	Tags    []string          `query:"tag"`
+	IDs     []int             `query:"ids,comma"`
+	Roles   []string          `query:"roles,brackets"`
+	Since   time.Time         `query:"since,omitempty" layout:"2006-01-02"`
+	Before  time.Time         `query:"before,unix,omitempty"`
+	Updated time.Time         `query:"updated,omitempty"`
+	Filter  UserFilter        `query:"filter"`
+	Labels  map[string]string `query:"label,omitempty"`
+	Level   Level             `query:"level"`
+	Score   float64           `query:"score,omitempty"`
+	Secret  string            `query:"-"`
+	Active  bool
+}
+
+func TestSnowyEncodeQuery(t *testing.T) {
+	t.Run("struct tags", func(t *testing.T) {
+		admin := true
+		values, err := snowy.EncodeQuery(&ListUsers{
+			Paging:  Paging{Limit: 50},
+			Tags:    []string{"a", "b"},
+			IDs:     []int{1, 2, 3},
+			Roles:   []string{"admin", "owner"},
+			Since:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			Before:  time.Unix(1700000000, 0),
+			Filter:  UserFilter{Status: "active", Admin: &admin},
+			Labels:  map[string]string{"env": "prod", "app": "api"},
+			Level:   3,
+			Score:   0.5,
+			Secret:  "hidden",
+			Updated: time.Time{},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, url.Values{
+			"limit":          {"50"},
+			"tag":            {"a", "b"},
+			"ids":            {"1,2,3"},
+			"roles[]":        {"admin", "owner"},
+			"since":          {"2024-03-01"},
+			"before":         {"1700000000"},
+			"filter[status]": {"active"},
+			"filter[admin]":  {"true"},
+			"label[app]":     {"api"},
+			"label[env]":     {"prod"},
+			"level":          {"***"},
+			"score":          {"0.5"},
+			"Active":         {"false"},
+		}, values)
+	})
+
+	t.Run("keeps zero values without omitempty", func(t *testing.T) {
+		values, err := snowy.EncodeQuery(ListUsers{})
+		assert.Nil(t, err)
+		assert.Equal(t, url.Values{
+			"level":  {""},
+			"Active": {"false"},
+		}, values)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := snowy.EncodeQuery([]string{"a"})
+		assert.ErrorContains(t, err, "unsupported type")
+
+		_, err = snowy.EncodeQuery(ListUsers{Level: -1})
+		assert.ErrorContains(t, err, "field Level: negative level")
+
+		_, err = snowy.EncodeQuery(struct {
+			Nested []UserFilter `query:"nested"`
+		}{Nested: []UserFilter{{}}})
+		assert.ErrorContains(t, err, "unsupported slice element type")
+	})
+}
+
+func TestSnowyQueryParams(t *testing.T) {
+	var rawQuery string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	t.Run("escapes and sorts parameters", func(t *testing.T) {
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{
+			QueryParams: map[string]string{"q": "a&b=c d", "b": "2", "a": "1"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "a=1&b=2&q=a%26b%3Dc+d", rawQuery)
+	})
+
+	t.Run("merges with the url query", func(t *testing.T) {
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL+"/users?page=2&sort=name#top", nil, snowy.RequestData{
+			Query:       ListUsers{Tags: []string{"x", "y"}, Search: "john"},
+			QueryParams: map[string]string{"page": "3", "search": "jane"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "sort=name&Active=false&level=&page=3&search=jane&tag=x&tag=y", rawQuery)
+	})
+
+	t.Run("keeps the url query as written", func(t *testing.T) {
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL+"?flag&a=1;b=2&c=1&c=2&d=%zz", nil, snowy.RequestData{
+			QueryParams: map[string]string{"c": "3"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "flag&a=1;b=2&d=%zz&c=3", rawQuery)
+	})
+
+	t.Run("url values", func(t *testing.T) {
+		_, err := snowy.Delete[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{
+			Query: url.Values{"id": {"1", "2"}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "id=1&id=2", rawQuery)
+	})
+
+	t.Run("leaves urls without parameters untouched", func(t *testing.T) {
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL+"?z=1&a=2", nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "z=1&a=2", rawQuery)
+	})
+
+	t.Run("encoding errors", func(t *testing.T) {
+		_, err := snowy.Post[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{Query: 42})
+		assert.ErrorContains(t, err, "encoding query")
+	})
+}
+
+func TestSnowyPaginateQuery(t *testing.T) {
+	type search struct {
+		Tags []string `query:"tag"`
+		Page int      `query:"page,omitempty"`
+	}
+	var queries []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+ts.URL+`/?page=2&tag=a&tag=b>; rel="next"`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]string{"item"})
+	}))
+	defer ts.Close()
+
+	pager := snowy.Pager[[]string, string]{
+		Items: func(page *[]string) []string { return *page },
+		Next:  snowy.NextLink[[]string](),
+	}
+	items := 0
+	for _, err := range snowy.Paginate(snowy.Config{}, ts.URL, nil, snowy.RequestData{Query: search{Tags: []string{"a", "b"}}}, pager) {
+		assert.Nil(t, err)
+		items++
+	}
+	assert.Equal(t, 2, items)
+	assert.Equal(t, []string{"tag=a&tag=b", "page=2&tag=a&tag=b"}, queries)
+}
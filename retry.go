@@ -0,0 +1,137 @@
+package snowy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. The zero value disables
+// retries, so a request is attempted exactly once.
+//
+// Delays grow exponentially from BaseDelay and are capped by MaxDelay. With Jitter
+// enabled the delay is drawn uniformly from [0, delay) ("full jitter"). A Retry-After
+// header sent with a retryable status code takes precedence over the computed delay;
+// if it asks for longer than MaxDelay the response is returned instead of waiting.
+type RetryPolicy struct {
+	MaxAttempts          int           // Total number of attempts, including the first one
+	BaseDelay            time.Duration // Defaults to 100ms
+	MaxDelay             time.Duration // Defaults to 30s
+	Jitter               bool
+	RetryableStatusCodes []int            // Defaults to 408, 429, 500, 502, 503 and 504
+	RetryableError       func(error) bool // Defaults to every error except the end of the caller's context
+	IgnoreRetryAfter     bool
+}
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (p RetryPolicy) baseDelay() time.Duration {
+	if p.BaseDelay <= 0 {
+		return 100 * time.Millisecond
+	}
+	return p.BaseDelay
+}
+
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return 30 * time.Second
+	}
+	return p.MaxDelay
+}
+
+func (p RetryPolicy) retryableStatus(code int) bool {
+	if p.RetryableStatusCodes == nil {
+		return slices.Contains(defaultRetryableStatusCodes, code)
+	}
+	return slices.Contains(p.RetryableStatusCodes, code)
+}
+
+// retryableError reports whether err is worth another attempt. By default an
+// attempt that ran out of Config.Timeout is retried, as long as ctx, the context
+// of the whole request, is still live.
+func (p RetryPolicy) retryableError(ctx context.Context, err error) bool {
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return true
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// backoff returns the delay before the attempt following the given one.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.maxDelay()
+	if shift := attempt - 1; shift < 62 {
+		if d := p.baseDelay() << shift; d > 0 && d < delay {
+			delay = d
+		}
+	}
+	if p.Jitter {
+		delay = rand.N(delay)
+	}
+	return delay
+}
+
+// next reports whether the attempt that produced res and err should be retried,
+// and how long to wait before doing so. A nil response means the request failed
+// before a response was received; a response is only retried when it comes with
+// an error, that is, when its status code is not acceptable.
+func (p RetryPolicy) next(ctx context.Context, attempt int, res *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || (res != nil && err == nil) {
+		return 0, false
+	}
+	if res == nil {
+		return p.backoff(attempt), p.retryableError(ctx, err)
+	}
+	if !p.retryableStatus(res.StatusCode) {
+		return 0, false
+	}
+	if !p.IgnoreRetryAfter {
+		if delay, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			return delay, delay <= p.maxDelay()
+		}
+	}
+	return p.backoff(attempt), true
+}
+
+// parseRetryAfter parses a Retry-After header given either as a number of seconds
+// or as an HTTP-date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,167 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyRetry(t *testing.T) {
+	retry := snowy.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+		Jitter:      true,
+	}
+
+	t.Run("retries until success", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(TestResponse{Message: "success"})
+		}))
+		defer ts.Close()
+
+		res, err := snowy.Get[TestResponse](snowy.Config{Retry: retry}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.NotNil(t, res)
+		assert.Equal(t, "success", res.Data.Message)
+		assert.Equal(t, 3, res.Attempts)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("reports attempts when exhausted", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer ts.Close()
+
+		res, err := snowy.Get[TestResponse](snowy.Config{Retry: retry}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, res)
+		assert.IsType(t, &snowy.RequestError{}, err)
+		assert.Equal(t, http.StatusBadGateway, err.(*snowy.RequestError).StatusCode)
+		assert.Equal(t, 3, err.(*snowy.RequestError).Attempts)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("does not retry other status codes", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{Retry: retry}, ts.URL, nil, snowy.RequestData{})
+		assert.IsType(t, &snowy.RequestError{}, err)
+		assert.Equal(t, 1, err.(*snowy.RequestError).Attempts)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("replays request body", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var user FakeUser
+			err := json.NewDecoder(r.Body).Decode(&user)
+			assert.Nil(t, err)
+			assert.Equal(t, "123", user.ID)
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		res, err := snowy.Post[TestResponse](snowy.Config{Retry: retry}, ts.URL, snowy.Headers{}, snowy.RequestData{
+			JsonData: FakeUser{ID: "123"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, res.Attempts)
+	})
+
+	t.Run("honors retry after", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch calls.Add(1) {
+			case 1:
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			case 2:
+				w.Header().Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Retry: snowy.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, res.Attempts)
+	})
+
+	t.Run("gives up when retry after exceeds max delay", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		_, err := snowy.Get[TestResponse](snowy.Config{Retry: retry}, ts.URL, nil, snowy.RequestData{})
+		assert.IsType(t, &snowy.RequestError{}, err)
+		assert.Equal(t, 1, err.(*snowy.RequestError).Attempts)
+	})
+
+	t.Run("retries network errors", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				assert.Nil(t, err)
+				conn.Close()
+				return
+			}
+			io.WriteString(w, `{"message":"success"}`)
+		}))
+		defer ts.Close()
+
+		res, err := snowy.Get[TestResponse](snowy.Config{Retry: retry}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "success", res.Data.Message)
+		assert.Equal(t, 2, res.Attempts)
+	})
+
+	t.Run("custom retryable errors", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Retry: retry}
+		config.Retry.RetryableError = func(error) bool { return false }
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+		assert.Nil(t, res)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
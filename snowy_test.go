@@ -1,9 +1,11 @@
 package snowy_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -424,6 +426,96 @@ func TestSnowyDelete(t *testing.T) {
 	})
 }
 
+func TestSnowyContext(t *testing.T) {
+	t.Run("context cancels the request", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer ts.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := snowy.PostCtx[TestResponse](ctx, snowy.Config{Timeout: 10 * time.Second}, ts.URL, nil, snowy.RequestData{JsonData: FakeUser{ID: "1"}})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("context replaces config context", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		config := snowy.Config{Ctx: canceled}
+		_, err := snowy.Delete[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		res, err := snowy.DeleteCtx[TestResponse](context.Background(), config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	t.Run("timeout limits each attempt", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"success"}`))
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{
+			Timeout: 50 * time.Millisecond,
+			Retry: snowy.RetryPolicy{
+				MaxAttempts: 3,
+				BaseDelay:   time.Millisecond,
+			},
+		}
+		res, err := snowy.GetCtx[TestResponse](context.Background(), config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "success", res.Data.Message)
+		assert.Equal(t, 2, res.Attempts)
+	})
+
+	t.Run("expired caller context is not retried", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-r.Context().Done()
+		}))
+		defer ts.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		config := snowy.Config{Timeout: time.Second, Retry: snowy.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+		_, err := snowy.GetCtx[TestResponse](ctx, config, ts.URL, nil, snowy.RequestData{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("timeout covers the response body", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":`))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer ts.Close()
+
+		_, err := snowy.GetCtx[TestResponse](context.Background(), snowy.Config{Timeout: 50 * time.Millisecond}, ts.URL, nil, snowy.RequestData{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
 func TestSnowyRequestError(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		err := snowy.RequestError{
@@ -0,0 +1,250 @@
+package snowy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single Server-Sent Event. Data holds the event data decoded as JSON
+// into T, and Raw the data as it was received.
+type Event[T any] struct {
+	ID    string
+	Event string
+	Data  *T
+	Raw   string
+	Retry time.Duration // Reconnection time requested by the server, if any
+}
+
+// defaultEventRetry is the reconnection time used until the server sends one.
+const defaultEventRetry = 3 * time.Second
+
+// Events connects to a text/event-stream endpoint and yields its events. When the
+// connection is lost the stream is resumed after the reconnection time, sending
+// the last event ID received in the Last-Event-ID header.
+//
+// Events whose data cannot be decoded into T are yielded together with the decoding
+// error and the iteration continues, so sentinel messages such as "[DONE]" can be
+// handled through Event.Raw. Network errors, including a connection lost in the
+// middle of the stream, are yielded the same way, and the connection is attempted
+// again after the reconnection time; a stream closed by the server is resumed
+// without an error. The iteration ends when the loop is abandoned, when Config.Ctx
+// is done, when the server answers with 204 No Content, or after yielding the error
+// of a response with an unacceptable status code, a content type other than
+// text/event-stream or an event larger than 1 MiB.
+//
+//	for event, err := range snowy.Events[Notification](config, "https://api.example.com/notifications", nil, snowy.RequestData{}) {
+//		if err != nil {
+//			log.Println(err)
+//			continue
+//		}
+//		fmt.Println(event.Event, event.Data.Message)
+//	}
+func Events[T any](config Config, url string, headers map[string]string, query RequestData) iter.Seq2[Event[T], error] {
+	return func(yield func(Event[T], error) bool) {
+		config = config.withDefaults()
+		client, release, err := streamingClient(config)
+		if err != nil {
+			yield(Event[T]{}, err)
+			return
+		}
+		defer release()
+		url, err := buildURL(url, query)
+		if err != nil {
+			yield(Event[T]{}, err)
+			return
+		}
+
+		retry := defaultEventRetry
+		lastEventID := ""
+		for {
+			headers := maps.Clone(headers)
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers["Accept"] = "text/event-stream"
+			headers["Cache-Control"] = "no-cache"
+			if lastEventID != "" {
+				headers["Last-Event-ID"] = lastEventID
+			}
+
+			res, _, err := execute(config.Ctx, config, client, 0, http.MethodGet, url, headers, nil)
+			if err != nil {
+				var reqErr *RequestError
+				if !yield(Event[T]{}, err) || errors.As(err, &reqErr) || config.Ctx.Err() != nil {
+					return
+				}
+				if err := sleep(config.Ctx, retry); err != nil {
+					yield(Event[T]{}, err)
+					return
+				}
+				continue
+			}
+			if res.StatusCode == http.StatusNoContent {
+				res.Body.Close()
+				return
+			}
+			if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+				res.Body.Close()
+				yield(Event[T]{}, fmt.Errorf("unexpected content type: %q", res.Header.Get("Content-Type")))
+				return
+			}
+
+			stopped := false
+			for event, err := range readEvents(res.Body, lastEventID) {
+				if err != nil {
+					if err == io.EOF || config.Ctx.Err() != nil {
+						break
+					}
+					// An event over the size limit would be sent again on every
+					// reconnection, so it ends the iteration.
+					err = fmt.Errorf("reading event stream: %w", err)
+					if !yield(Event[T]{}, err) || errors.Is(err, bufio.ErrTooLong) {
+						stopped = true
+					}
+					break
+				}
+				lastEventID = event.id
+				if event.retry > 0 {
+					retry = event.retry
+				}
+				if event.data == nil {
+					continue
+				}
+				e := Event[T]{ID: event.id, Event: event.event, Raw: *event.data, Retry: event.retry}
+				var v T
+				var decodeErr error
+				if decodeErr = json.Unmarshal([]byte(e.Raw), &v); decodeErr == nil {
+					e.Data = &v
+				} else {
+					decodeErr = fmt.Errorf("decoding event data: %w", decodeErr)
+				}
+				if !yield(e, decodeErr) {
+					stopped = true
+					break
+				}
+			}
+			res.Body.Close()
+			if stopped {
+				return
+			}
+			if err := sleep(config.Ctx, retry); err != nil {
+				yield(Event[T]{}, err)
+				return
+			}
+		}
+	}
+}
+
+// rawEvent is a parsed event. data is nil for blocks without data fields, which
+// only update the last event ID or the reconnection time.
+type rawEvent struct {
+	id    string
+	event string
+	data  *string
+	retry time.Duration
+}
+
+// readEvents parses an event stream following the WHATWG HTML specification. The
+// id of every event is the last event ID seen so far, starting from lastEventID.
+func readEvents(r io.Reader, lastEventID string) iter.Seq2[rawEvent, error] {
+	return func(yield func(rawEvent, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		scanner.Split(scanEventLines)
+
+		event := rawEvent{id: lastEventID}
+		var data strings.Builder
+		hasData, hasFields := false, false
+		first := true
+		for scanner.Scan() {
+			line := scanner.Text()
+			if first {
+				line = strings.TrimPrefix(line, "\ufeff")
+				first = false
+			}
+			if line == "" {
+				if hasData {
+					value := strings.TrimSuffix(data.String(), "\n")
+					event.data = &value
+					if event.event == "" {
+						event.event = "message"
+					}
+				}
+				if hasFields {
+					if !yield(event, nil) {
+						return
+					}
+				}
+				event = rawEvent{id: event.id}
+				data.Reset()
+				hasData, hasFields = false, false
+				continue
+			}
+			if strings.HasPrefix(line, ":") {
+				continue
+			}
+			hasFields = true
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event.event = value
+			case "data":
+				data.WriteString(value)
+				data.WriteByte('\n')
+				hasData = true
+			case "id":
+				if !strings.ContainsRune(value, 0) {
+					event.id = value
+				}
+			case "retry":
+				if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+					event.retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		yield(rawEvent{}, err)
+	}
+}
+
+// scanEventLines splits lines terminated by CRLF, LF or CR.
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		// An incomplete event at the end of the stream is discarded.
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,216 @@
+package snowy_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyEvents(t *testing.T) {
+	t.Run("parses events and reconnects", func(t *testing.T) {
+		var connections atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+			assert.Equal(t, "token", r.Header.Get("X-Token"))
+			switch connections.Add(1) {
+			case 1:
+				assert.Equal(t, "", r.Header.Get("Last-Event-ID"))
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, "\ufeff: keep alive\nretry: 5\n\n")
+				io.WriteString(w, "id: 1\r\nevent: created\r\ndata: {\"id\":\"1\",\r\ndata: \"username\":\"test\"}\r\n\r\n")
+				io.WriteString(w, "id: 2\rdata:{\"id\":\"2\"}\r\r")
+				io.WriteString(w, "data: {\"id\":\"incomplete\"}\n")
+			case 2:
+				assert.Equal(t, "2", r.Header.Get("Last-Event-ID"))
+				w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+				io.WriteString(w, "data: {\"id\":\"3\"}\n\n")
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		defer ts.Close()
+
+		var events []snowy.Event[FakeUser]
+		for event, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, snowy.Headers{"X-Token": "token"}, snowy.RequestData{}) {
+			assert.Nil(t, err)
+			events = append(events, event)
+		}
+
+		assert.Len(t, events, 3)
+		assert.Equal(t, "1", events[0].ID)
+		assert.Equal(t, "created", events[0].Event)
+		assert.Equal(t, "{\"id\":\"1\",\n\"username\":\"test\"}", events[0].Raw)
+		assert.Equal(t, "test", events[0].Data.Username)
+		assert.Equal(t, "2", events[1].ID)
+		assert.Equal(t, "message", events[1].Event)
+		assert.Equal(t, "2", events[1].Data.ID)
+		assert.Equal(t, "2", events[2].ID)
+		assert.Equal(t, "3", events[2].Data.ID)
+		assert.Equal(t, int32(3), connections.Load())
+	})
+
+	t.Run("yields decoding errors and continues", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Last-Event-ID") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "retry: 1\nid: 1\ndata: [DONE]\n\ndata: {\"id\":\"2\"}\n\n")
+		}))
+		defer ts.Close()
+
+		var raws []string
+		var errs []error
+		for event, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			raws = append(raws, event.Raw)
+			errs = append(errs, err)
+		}
+		assert.Equal(t, []string{"[DONE]", `{"id":"2"}`}, raws)
+		assert.ErrorContains(t, errs[0], "decoding event data")
+		assert.Nil(t, errs[1])
+	})
+
+	t.Run("stops when abandoned", func(t *testing.T) {
+		var connections atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			connections.Add(1)
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"id\":\"1\"}\n\ndata: {\"id\":\"2\"}\n\n")
+		}))
+		defer ts.Close()
+
+		for event, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			assert.Nil(t, err)
+			assert.Equal(t, "1", event.Data.ID)
+			break
+		}
+		assert.Equal(t, int32(1), connections.Load())
+	})
+
+	t.Run("reconnects after network errors", func(t *testing.T) {
+		var connections atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch connections.Add(1) {
+			case 1:
+				// A fresh connection, which the transport does not retry on its own, is
+				// made for the next request.
+				w.Header().Set("Connection", "close")
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, "retry: 1\nid: 1\ndata: {\"id\":\"1\"}\n\n")
+			case 2:
+				// The server is unavailable: the connection is closed without a response.
+				conn, _, err := w.(http.Hijacker).Hijack()
+				assert.Nil(t, err)
+				conn.Close()
+			case 3:
+				assert.Equal(t, "1", r.Header.Get("Last-Event-ID"))
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, "id: 2\ndata: {\"id\":\"2\"}\n\n")
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		defer ts.Close()
+
+		var ids []string
+		var errs []error
+		for event, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ids = append(ids, event.Data.ID)
+		}
+		assert.Equal(t, []string{"1", "2"}, ids)
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "executing request")
+		assert.Equal(t, int32(4), connections.Load())
+	})
+
+	t.Run("yields errors of interrupted streams", func(t *testing.T) {
+		var connections atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if connections.Add(1) > 1 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			// The connection is reset in the middle of a chunked response.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.Nil(t, err)
+			io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n")
+			io.WriteString(conn, "1b\r\nretry: 1\ndata: {\"id\":\"1\"}\n\n\r\n")
+			conn.Close()
+		}))
+		defer ts.Close()
+
+		var ids []string
+		var errs []error
+		for event, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ids = append(ids, event.Data.ID)
+		}
+		assert.Equal(t, []string{"1"}, ids)
+		assert.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], io.ErrUnexpectedEOF)
+		assert.Equal(t, int32(2), connections.Load())
+	})
+
+	t.Run("stops on events over the size limit", func(t *testing.T) {
+		var connections atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			connections.Add(1)
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "retry: 10\ndata: "+strings.Repeat("x", 2<<20)+"\n\n")
+		}))
+		defer ts.Close()
+
+		var errs []error
+		for _, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], bufio.ErrTooLong)
+		assert.Equal(t, int32(1), connections.Load())
+	})
+
+	t.Run("rejects other content types", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, "{}")
+		}))
+		defer ts.Close()
+
+		var errs []error
+		for _, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "unexpected content type")
+	})
+
+	t.Run("unexpected status code", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer ts.Close()
+
+		var errs []error
+		for _, err := range snowy.Events[FakeUser](snowy.Config{}, ts.URL, nil, snowy.RequestData{}) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.IsType(t, &snowy.RequestError{}, errs[0])
+	})
+}
@@ -0,0 +1,185 @@
+package snowy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// Stream sends the request and decodes the response body one element at a time,
+// so that large responses never have to be held in memory. The body may be either
+// a top-level JSON array or a stream of newline-delimited JSON values (NDJSON).
+//
+// The iteration stops at the first error, which is yielded with the zero value of T.
+// The response body is closed when the iteration ends, including when the loop is
+// abandoned early. Config.Timeout only limits the time to receive the response
+// headers; use Config.Ctx to bound the whole stream.
+//
+//	for record, err := range snowy.Stream[Record](config, http.MethodGet, "https://api.example.com/export", nil, snowy.RequestData{}) {
+//		if err != nil {
+//			return err
+//		}
+//		process(record)
+//	}
+func Stream[T any](config Config, method, url string, headers map[string]string, data RequestData) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		config = config.withDefaults()
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		if _, ok := headers["Accept"]; !ok {
+			headers["Accept"] = "application/json, application/x-ndjson"
+		}
+		url, err := buildURL(url, data)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		headers = parseHeaders(headers, data)
+		body := func() (io.Reader, error) { return parseBody(data) }
+		if method == http.MethodGet || method == http.MethodHead {
+			body = nil
+		}
+
+		client, release, err := streamingClient(config)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer release()
+		res, _, err := execute(config.Ctx, config, client, 0, method, url, headers, body)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer res.Body.Close()
+
+		reader := bufio.NewReader(res.Body)
+		dec := json.NewDecoder(reader)
+		if !isJSONLines(res.Header.Get("Content-Type")) && startsWithArray(reader) {
+			if _, err := dec.Token(); err != nil {
+				yield(zero, fmt.Errorf("decoding stream: %w", err))
+				return
+			}
+			for dec.More() {
+				var v T
+				if err := dec.Decode(&v); err != nil {
+					yield(zero, fmt.Errorf("decoding stream element: %w", err))
+					return
+				}
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				yield(zero, fmt.Errorf("decoding stream: %w", err))
+			}
+			return
+		}
+
+		for {
+			var v T
+			if err := dec.Decode(&v); err != nil {
+				if err != io.EOF {
+					yield(zero, fmt.Errorf("decoding stream element: %w", err))
+				}
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// streamingClient shares the transport of the client for config, with
+// Config.Timeout limiting only the time to receive the response headers. A
+// deadline on the whole attempt, or the timeout of a client set in
+// Config.HTTPClient, would otherwise cut off long running response bodies.
+func streamingClient(config Config) (*http.Client, func(), error) {
+	client, release, err := getClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	streaming := *client
+	streaming.Timeout = 0
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	streaming.Transport = &headerTimeout{transport: transport, timeout: config.Timeout}
+	return &streaming, release, nil
+}
+
+// headerTimeout limits the time a round trip may take to receive the response
+// headers, leaving the body unbounded.
+type headerTimeout struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+func (t *headerTimeout) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+	res, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			res.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("timeout awaiting response headers after %s", t.timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// startsWithArray reports whether the next non-whitespace byte is the start of a
+// JSON array, without consuming it.
+func startsWithArray(r *bufio.Reader) bool {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		r.UnreadByte()
+		return b == '['
+	}
+}
+
+func isJSONLines(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	}
+	return false
+}
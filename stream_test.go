@@ -0,0 +1,143 @@
+package snowy_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyStream(t *testing.T) {
+	t.Run("json array", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, ` [{"id":"1"}, {"id":"2"},`+"\n"+`{"id":"3"}]`)
+		}))
+		defer ts.Close()
+
+		seq := snowy.Stream[FakeUser](snowy.Config{}, http.MethodGet, ts.URL, nil, snowy.RequestData{})
+		assert.Equal(t, []string{"1", "2", "3"}, collectIDs(t, seq))
+	})
+
+	t.Run("newline delimited json", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var filter map[string]string
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&filter))
+			assert.Equal(t, "active", filter["status"])
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, "{\"id\":\"1\"}\n{\"id\":\"2\"}\n")
+		}))
+		defer ts.Close()
+
+		seq := snowy.Stream[FakeUser](snowy.Config{}, http.MethodPost, ts.URL, nil, snowy.RequestData{
+			JsonData: map[string]string{"status": "active"},
+		})
+		assert.Equal(t, []string{"1", "2"}, collectIDs(t, seq))
+	})
+
+	t.Run("abandoned iteration closes body", func(t *testing.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer close(done)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for i := 0; ; i++ {
+				if _, err := fmt.Fprintf(w, "{\"id\":\"%d\"}\n", i); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}))
+		defer ts.Close()
+
+		var ids []string
+		for user, err := range snowy.Stream[FakeUser](snowy.Config{}, http.MethodGet, ts.URL, nil, snowy.RequestData{}) {
+			assert.Nil(t, err)
+			ids = append(ids, user.ID)
+			if len(ids) == 3 {
+				break
+			}
+		}
+		assert.Equal(t, []string{"0", "1", "2"}, ids)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream was not closed")
+		}
+	})
+
+	t.Run("mid-stream error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `[{"id":"1"}, {"id": oops}]`)
+		}))
+		defer ts.Close()
+
+		var ids []string
+		var errs []error
+		for user, err := range snowy.Stream[FakeUser](snowy.Config{}, http.MethodGet, ts.URL, nil, snowy.RequestData{}) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ids = append(ids, user.ID)
+		}
+		assert.Equal(t, []string{"1"}, ids)
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "decoding stream element")
+	})
+
+	t.Run("unexpected status code", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		var errs []error
+		for _, err := range snowy.Stream[FakeUser](snowy.Config{}, http.MethodGet, ts.URL, nil, snowy.RequestData{}) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.IsType(t, &snowy.RequestError{}, errs[0])
+		assert.Equal(t, http.StatusNotFound, errs[0].(*snowy.RequestError).StatusCode)
+	})
+
+	t.Run("timeout only applies to headers", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "{\"id\":\"1\"}\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+			io.WriteString(w, "{\"id\":\"2\"}\n")
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Timeout: 20 * time.Millisecond}
+		seq := snowy.Stream[FakeUser](config, http.MethodGet, ts.URL, nil, snowy.RequestData{})
+		assert.Equal(t, []string{"1", "2"}, collectIDs(t, seq))
+	})
+
+	t.Run("timeout awaiting headers", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+		}))
+		defer ts.Close()
+
+		config := snowy.Config{Timeout: 10 * time.Millisecond}
+		var errs []error
+		for _, err := range snowy.Stream[FakeUser](config, http.MethodGet, ts.URL, nil, snowy.RequestData{}) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "timeout awaiting response headers")
+	})
+}
@@ -0,0 +1,119 @@
+package snowy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// TLSConfig configures the TLS connections of a client: client certificates for
+// mutual TLS, the certificate authorities trusted to verify servers, and public key
+// pins.
+//
+//	config := snowy.Config{
+//		TLS: &snowy.TLSConfig{
+//			CertFile: "client.pem",
+//			KeyFile:  "client-key.pem",
+//			CAFile:   "internal-ca.pem",
+//			Pins:     []string{"sha256/7HIpactkIAq2Y49orFOOQKurWxmmSFZhBCoQYcRhJ3Y="},
+//		},
+//	}
+//
+// Configurations with different TLS settings never share connections.
+type TLSConfig struct {
+	CertFile     string            // PEM encoded client certificate, used with KeyFile
+	KeyFile      string            // PEM encoded private key of CertFile
+	Certificates []tls.Certificate // Client certificates, in addition to CertFile
+	CertReloader *CertReloader     // Client certificate reloaded from rotated files, replaces CertFile and Certificates
+	RootCAs      *x509.CertPool    // Certificate authorities trusted to verify servers, defaults to the system pool
+	CAFile       string            // PEM bundle of certificate authorities, added to RootCAs
+	MinVersion   uint16            // Minimum TLS version, defaults to TLS 1.2
+	ServerName   string            // Server name used for SNI and certificate verification, defaults to the host
+	Pins         []string          // Base64 SHA-256 of the SubjectPublicKeyInfo of a certificate in the verified chain, optionally prefixed with "sha256/"
+}
+
+// key identifies the settings in the client cache key.
+func (t *TLSConfig) key() string {
+	if t == nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "cert=%s:%s;reloader=%p;ca=%s;roots=%p;min=%d;sni=%s", t.CertFile, t.KeyFile, t.CertReloader, t.CAFile, t.RootCAs, t.MinVersion, t.ServerName)
+	for _, cert := range t.Certificates {
+		for _, der := range cert.Certificate {
+			sum := sha256.Sum256(der)
+			fmt.Fprintf(&b, ";certificate=%x", sum)
+		}
+	}
+	pins := slices.Clone(t.Pins)
+	slices.Sort(pins)
+	fmt.Fprintf(&b, ";pins=%s", strings.Join(pins, ","))
+	return b.String()
+}
+
+// clientConfig builds the *tls.Config of the transport, loading the files.
+func (t *TLSConfig) clientConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		ServerName:   t.ServerName,
+		RootCAs:      t.RootCAs,
+		Certificates: slices.Clone(t.Certificates),
+	}
+	if t.MinVersion != 0 {
+		config.MinVersion = t.MinVersion
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if t.CertReloader != nil {
+		config.GetClientCertificate = t.CertReloader.GetClientCertificate
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		if config.RootCAs == nil {
+			config.RootCAs = x509.NewCertPool()
+		} else {
+			config.RootCAs = config.RootCAs.Clone()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("reading CA file: no certificates found in %s", t.CAFile)
+		}
+	}
+	if len(t.Pins) > 0 {
+		pins := make(map[string]bool, len(t.Pins))
+		for _, pin := range t.Pins {
+			pins[strings.TrimPrefix(pin, "sha256/")] = true
+		}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, chain := range state.VerifiedChains {
+				for _, cert := range chain {
+					if pins[SPKIPin(cert)] {
+						return nil
+					}
+				}
+			}
+			return errors.New("certificate pin mismatch")
+		}
+	}
+	return config, nil
+}
+
+// SPKIPin returns the base64 encoded SHA-256 of the SubjectPublicKeyInfo of cert,
+// as used in TLSConfig.Pins.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+package snowy_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeClientCertificate creates a self-signed client certificate valid until
+// notAfter and writes it, with its key, as PEM files in dir.
+func writeClientCertificate(t *testing.T, dir, commonName string, notAfter time.Time) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err = x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+func writeServerCA(t *testing.T, ts *httptest.Server) string {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.Nil(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600))
+	return caFile
+}
+
+func TestSnowyTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"secure"}`))
+	}))
+	defer ts.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	t.Run("root CA pool", func(t *testing.T) {
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL, nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+
+		res, err := snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots}}, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "secure", res.Data.Message)
+	})
+
+	t.Run("negotiates HTTP/2", func(t *testing.T) {
+		h2 := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"` + r.Proto + `"}`))
+		}))
+		h2.EnableHTTP2 = true
+		h2.StartTLS()
+		defer h2.Close()
+		roots := x509.NewCertPool()
+		roots.AddCert(h2.Certificate())
+
+		res, err := snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots}}, h2.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "HTTP/2.0", res.Data.Message)
+	})
+
+	t.Run("CA file", func(t *testing.T) {
+		config := snowy.Config{TLS: &snowy.TLSConfig{CAFile: writeServerCA(t, ts)}}
+		res, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "secure", res.Data.Message)
+
+		config = snowy.Config{TLS: &snowy.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}}
+		_, err = snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("server name override", func(t *testing.T) {
+		config := snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots, ServerName: "example.com"}}
+		_, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+
+		config = snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots, ServerName: "other.test"}}
+		_, err = snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("minimum version", func(t *testing.T) {
+		old := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		old.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+		old.StartTLS()
+		defer old.Close()
+		pool := x509.NewCertPool()
+		pool.AddCert(old.Certificate())
+
+		_, err := snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: pool}}, old.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		_, err = snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: pool, MinVersion: tls.VersionTLS13}}, old.URL, nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("public key pins", func(t *testing.T) {
+		pin := snowy.SPKIPin(ts.Certificate())
+		config := snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots, Pins: []string{"sha256/" + pin}}}
+		_, err := snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+
+		config = snowy.Config{TLS: &snowy.TLSConfig{RootCAs: roots, Pins: []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}}}
+		_, err = snowy.Get[TestResponse](config, ts.URL, nil, snowy.RequestData{})
+		assert.ErrorContains(t, err, "certificate pin mismatch")
+	})
+
+	t.Run("client certificates", func(t *testing.T) {
+		certFile, keyFile, clientCert := writeClientCertificate(t, t.TempDir(), "snowy client", time.Now().Add(time.Hour))
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(clientCert)
+
+		mtls := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+		}))
+		mtls.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		mtls.StartTLS()
+		defer mtls.Close()
+		pool := x509.NewCertPool()
+		pool.AddCert(mtls.Certificate())
+
+		_, err := snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: pool}}, mtls.URL, nil, snowy.RequestData{})
+		assert.NotNil(t, err)
+
+		res, err := snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: pool, CertFile: certFile, KeyFile: keyFile}}, mtls.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "snowy client", res.Data.Message)
+
+		keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+		assert.Nil(t, err)
+		res, err = snowy.Get[TestResponse](snowy.Config{TLS: &snowy.TLSConfig{RootCAs: pool, Certificates: []tls.Certificate{keyPair}}}, mtls.URL, nil, snowy.RequestData{})
+		assert.Nil(t, err)
+		assert.Equal(t, "snowy client", res.Data.Message)
+	})
+}
@@ -0,0 +1,346 @@
+package snowy
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExpandURI expands the RFC 6570 URI template tmpl, up to level 4, with the
+// values in params: a map with string keys, or a struct, or pointer to one, whose
+// fields are named by their uri tag or else by the field name.
+//
+//	url, err := snowy.ExpandURI("https://api.example.com/users/{id}/files{/path*}{?fields*}", map[string]any{
+//		"id":     "john doe",                       // users/john%20doe
+//		"path":   []string{"docs", "a/b.txt"},      // /docs/a%2Fb.txt
+//		"fields": map[string]string{"size": "lg"},  // ?size=lg
+//	})
+//
+// Values are strings, numbers, booleans, times, encoding.TextMarshaler, slices of
+// them for lists, and maps with string keys for associative arrays. Missing and nil
+// values are undefined and omitted from the expansion, as are empty lists and maps.
+func ExpandURI(tmpl string, params any) (string, error) {
+	lookup, err := uriParams(params)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for tmpl != "" {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			writeURILiteral(&b, tmpl)
+			break
+		}
+		writeURILiteral(&b, tmpl[:start])
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("expanding URI template: unclosed expression at offset %d", start)
+		}
+		if err := expandURIExpression(&b, tmpl[start+1:start+end], lookup); err != nil {
+			return "", fmt.Errorf("expanding URI template: %w", err)
+		}
+		tmpl = tmpl[start+end+1:]
+	}
+	return b.String(), nil
+}
+
+// uriOperator describes the expansion of an operator, as in RFC 6570 appendix A.
+type uriOperator struct {
+	first    string
+	sep      string
+	named    bool
+	ifEmpty  string
+	reserved bool // Allow reserved characters and percent-encoded triplets
+}
+
+var uriOperators = map[byte]uriOperator{
+	'+': {first: "", sep: ",", reserved: true},
+	'#': {first: "#", sep: ",", reserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+}
+
+func expandURIExpression(b *strings.Builder, expr string, lookup func(string) (reflect.Value, bool)) error {
+	op := uriOperator{sep: ","}
+	if expr != "" {
+		if o, ok := uriOperators[expr[0]]; ok {
+			op = o
+			expr = expr[1:]
+		} else if strings.ContainsRune("=,!@|", rune(expr[0])) {
+			return fmt.Errorf("reserved operator %q", expr[0])
+		}
+	}
+
+	first := true
+	for _, spec := range strings.Split(expr, ",") {
+		name, explode := strings.CutSuffix(spec, "*")
+		prefix := -1
+		if n, length, ok := strings.Cut(name, ":"); ok && !explode {
+			var err error
+			if prefix, err = strconv.Atoi(length); err != nil || prefix <= 0 || prefix >= 10000 {
+				return fmt.Errorf("invalid prefix length in %q", spec)
+			}
+			name = n
+		}
+		if !validURIVarName(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		expanded, defined, err := op.expand(name, value, explode, prefix)
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+		if !defined {
+			continue
+		}
+		if first {
+			b.WriteString(op.first)
+			first = false
+		} else {
+			b.WriteString(op.sep)
+		}
+		b.WriteString(expanded)
+	}
+	return nil
+}
+
+// expand returns the expansion of a single variable, and false when its value is
+// undefined.
+func (op uriOperator) expand(name string, value reflect.Value, explode bool, prefix int) (string, bool, error) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", false, nil
+		}
+		value = value.Elem()
+	}
+
+	var scalar queryField
+	if s, ok, err := scalar.scalar(value); ok || err != nil {
+		if err != nil {
+			return "", false, err
+		}
+		if prefix >= 0 && utf8.RuneCountInString(s) > prefix {
+			s = string([]rune(s)[:prefix])
+		}
+		return op.named1(name, op.encode(s)), true, nil
+	}
+	if prefix >= 0 {
+		return "", false, fmt.Errorf("prefix modifier applied to a composite value")
+	}
+
+	var pairs [][2]string // Items of a list have no key
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range value.Len() {
+			s, err := uriScalar(value.Index(i))
+			if err != nil {
+				return "", false, err
+			}
+			pairs = append(pairs, [2]string{"", s})
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return "", false, fmt.Errorf("unsupported map key type %s", value.Type().Key())
+		}
+		keys := value.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, key := range keys {
+			s, err := uriScalar(value.MapIndex(key))
+			if err != nil {
+				return "", false, err
+			}
+			pairs = append(pairs, [2]string{key.String(), s})
+		}
+	default:
+		return "", false, fmt.Errorf("unsupported type %s", value.Type())
+	}
+	if len(pairs) == 0 {
+		return "", false, nil
+	}
+	isMap := value.Kind() == reflect.Map
+
+	items := make([]string, 0, len(pairs)*2)
+	if !explode {
+		for _, pair := range pairs {
+			if isMap {
+				items = append(items, op.encode(pair[0]))
+			}
+			items = append(items, op.encode(pair[1]))
+		}
+		joined := strings.Join(items, ",")
+		if op.named {
+			return name + "=" + joined, true, nil
+		}
+		return joined, true, nil
+	}
+	for _, pair := range pairs {
+		switch {
+		case isMap:
+			if pair[1] == "" && op.named {
+				items = append(items, op.encode(pair[0])+op.ifEmpty)
+			} else {
+				items = append(items, op.encode(pair[0])+"="+op.encode(pair[1]))
+			}
+		case op.named:
+			items = append(items, op.named1(name, op.encode(pair[1])))
+		default:
+			items = append(items, op.encode(pair[1]))
+		}
+	}
+	return strings.Join(items, op.sep), true, nil
+}
+
+// named1 prefixes an encoded value with the variable name for named operators.
+func (op uriOperator) named1(name, encoded string) string {
+	if !op.named {
+		return encoded
+	}
+	if encoded == "" {
+		return name + op.ifEmpty
+	}
+	return name + "=" + encoded
+}
+
+func uriScalar(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	var scalar queryField
+	s, ok, err := scalar.scalar(v)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("unsupported element type %s", v.Type())
+	}
+	return s, nil
+}
+
+func (op uriOperator) encode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case op.reserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			b.WriteByte(c)
+		case op.reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// writeURILiteral copies the literal parts of a template, encoding the characters
+// not allowed in a URI.
+func writeURILiteral(b *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) || c == '%' || strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func validURIVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_':
+		case c == '.' && i > 0 && i < len(name)-1 && name[i-1] != '.':
+		case c == '%' && i+2 < len(name) && isHex(name[i+1]) && isHex(name[i+2]):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// uriParams returns a function looking up the values of template variables.
+func uriParams(params any) (func(string) (reflect.Value, bool), error) {
+	rv := reflect.ValueOf(params)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			break
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid, reflect.Pointer, reflect.Interface:
+		return func(string) (reflect.Value, bool) { return reflect.Value{}, false }, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("expanding URI template: unsupported map key type %s", rv.Type().Key())
+		}
+		return func(name string) (reflect.Value, bool) {
+			value := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			return value, value.IsValid()
+		}, nil
+	case reflect.Struct:
+		fields := make(map[string]reflect.Value)
+		rt := rv.Type()
+		for i := range rt.NumField() {
+			sf := rt.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := sf.Tag.Get("uri")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			fields[name] = rv.Field(i)
+		}
+		return func(name string) (reflect.Value, bool) {
+			value, ok := fields[name]
+			return value, ok
+		}, nil
+	}
+	return nil, fmt.Errorf("expanding URI template: unsupported parameters type %T", params)
+}
+
+// buildURL expands the URI template in rawURL when data has PathParams, and adds
+// the query parameters of data.
+func buildURL(rawURL string, data RequestData) (string, error) {
+	if data.PathParams != nil {
+		expanded, err := ExpandURI(rawURL, data.PathParams)
+		if err != nil {
+			return "", err
+		}
+		rawURL = expanded
+	}
+	return parseQueryParams(rawURL, data)
+}
@@ -0,0 +1,229 @@
+package snowy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brunobolting/go-snowy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowyExpandURI(t *testing.T) {
+	// Variables and expansions of the RFC 6570 section 3.2 examples. Associative
+	// arrays are expanded in key order, so the keys examples are reordered.
+	params := map[string]any{
+		"count":      []string{"one", "two", "three"},
+		"dom":        []string{"example", "com"},
+		"dub":        "me/too",
+		"hello":      "Hello World!",
+		"half":       "50%",
+		"var":        "value",
+		"who":        "fred",
+		"base":       "http://example.com/home/",
+		"path":       "/foo/bar",
+		"list":       []string{"red", "green", "blue"},
+		"keys":       map[string]string{"semi": ";", "dot": ".", "comma": ","},
+		"v":          6,
+		"x":          1024,
+		"y":          "768",
+		"empty":      "",
+		"empty_keys": map[string]string{},
+		"undef":      nil,
+	}
+	tests := map[string]string{
+		// Simple string expansion
+		"{var}":          "value",
+		"{hello}":        "Hello%20World%21",
+		"{half}":         "50%25",
+		"O{empty}X":      "OX",
+		"O{undef}X":      "OX",
+		"{x,y}":          "1024,768",
+		"{x,hello,y}":    "1024,Hello%20World%21,768",
+		"?{x,empty}":     "?1024,",
+		"?{x,undef}":     "?1024",
+		"?{undef,y}":     "?768",
+		"{var:3}":        "val",
+		"{var:30}":       "value",
+		"{list}":         "red,green,blue",
+		"{list*}":        "red,green,blue",
+		"{keys}":         "comma,%2C,dot,.,semi,%3B",
+		"{keys*}":        "comma=%2C,dot=.,semi=%3B",
+		"{count}":        "one,two,three",
+		"{empty_keys}":   "",
+		"{empty_keys*}":  "",
+		"{undef,count*}": "one,two,three",
+
+		// Reserved expansion
+		"{+var}":                    "value",
+		"{+hello}":                  "Hello%20World!",
+		"{+half}":                   "50%25",
+		"{base}index":               "http%3A%2F%2Fexample.com%2Fhome%2Findex",
+		"{+base}index":              "http://example.com/home/index",
+		"O{+empty}X":                "OX",
+		"{+path}/here":              "/foo/bar/here",
+		"here?ref={+path}":          "here?ref=/foo/bar",
+		"up{+path}{var}/here":       "up/foo/barvalue/here",
+		"{+x,hello,y}":              "1024,Hello%20World!,768",
+		"{+path,x}/here":            "/foo/bar,1024/here",
+		"{+path:6}/here":            "/foo/b/here",
+		"{+list}":                   "red,green,blue",
+		"{+keys}":                   "comma,,,dot,.,semi,;",
+		"{+keys*}":                  "comma=,,dot=.,semi=;",
+		"{+base}%7Bescaped%7D":      "http://example.com/home/%7Bescaped%7D",
+		"literal with spaces/{var}": "literal%20with%20spaces/value",
+
+		// Fragment expansion
+		"{#var}":         "#value",
+		"{#hello}":       "#Hello%20World!",
+		"{#half}":        "#50%25",
+		"foo{#empty}":    "foo#",
+		"foo{#undef}":    "foo",
+		"{#x,hello,y}":   "#1024,Hello%20World!,768",
+		"{#path,x}/here": "#/foo/bar,1024/here",
+		"{#path:6}/here": "#/foo/b/here",
+		"{#list}":        "#red,green,blue",
+		"{#list*}":       "#red,green,blue",
+		"{#keys}":        "#comma,,,dot,.,semi,;",
+
+		// Label expansion with dot-prefix
+		"{.who}":          ".fred",
+		"{.who,who}":      ".fred.fred",
+		"{.half,who}":     ".50%25.fred",
+		"www{.dom*}":      "www.example.com",
+		"X{.var}":         "X.value",
+		"X{.empty}":       "X.",
+		"X{.undef}":       "X",
+		"X{.var:3}":       "X.val",
+		"X{.list}":        "X.red,green,blue",
+		"X{.list*}":       "X.red.green.blue",
+		"X{.keys}":        "X.comma,%2C,dot,.,semi,%3B",
+		"X{.keys*}":       "X.comma=%2C.dot=..semi=%3B",
+		"X{.empty_keys}":  "X",
+		"X{.empty_keys*}": "X",
+
+		// Path segment expansion
+		"{/who}":          "/fred",
+		"{/who,who}":      "/fred/fred",
+		"{/half,who}":     "/50%25/fred",
+		"{/who,dub}":      "/fred/me%2Ftoo",
+		"{/var}":          "/value",
+		"{/var,empty}":    "/value/",
+		"{/var,undef}":    "/value",
+		"{/var,x}/here":   "/value/1024/here",
+		"{/var:1,var}":    "/v/value",
+		"{/list}":         "/red,green,blue",
+		"{/list*}":        "/red/green/blue",
+		"{/list*,path:4}": "/red/green/blue/%2Ffoo",
+		"{/keys}":         "/comma,%2C,dot,.,semi,%3B",
+		"{/keys*}":        "/comma=%2C/dot=./semi=%3B",
+
+		// Path-style parameter expansion
+		"{;who}":         ";who=fred",
+		"{;half}":        ";half=50%25",
+		"{;empty}":       ";empty",
+		"{;v,empty,who}": ";v=6;empty;who=fred",
+		"{;v,bar,who}":   ";v=6;who=fred",
+		"{;x,y}":         ";x=1024;y=768",
+		"{;x,y,empty}":   ";x=1024;y=768;empty",
+		"{;x,y,undef}":   ";x=1024;y=768",
+		"{;hello:5}":     ";hello=Hello",
+		"{;list}":        ";list=red,green,blue",
+		"{;list*}":       ";list=red;list=green;list=blue",
+		"{;keys}":        ";keys=comma,%2C,dot,.,semi,%3B",
+		"{;keys*}":       ";comma=%2C;dot=.;semi=%3B",
+
+		// Form-style query expansion
+		"{?who}":       "?who=fred",
+		"{?half}":      "?half=50%25",
+		"{?x,y}":       "?x=1024&y=768",
+		"{?x,y,empty}": "?x=1024&y=768&empty=",
+		"{?x,y,undef}": "?x=1024&y=768",
+		"{?var:3}":     "?var=val",
+		"{?list}":      "?list=red,green,blue",
+		"{?list*}":     "?list=red&list=green&list=blue",
+		"{?keys}":      "?keys=comma,%2C,dot,.,semi,%3B",
+		"{?keys*}":     "?comma=%2C&dot=.&semi=%3B",
+
+		// Form-style query continuation
+		"{&who}":         "&who=fred",
+		"{&half}":        "&half=50%25",
+		"?fixed=yes{&x}": "?fixed=yes&x=1024",
+		"{&x,y,empty}":   "&x=1024&y=768&empty=",
+		"{&var:3}":       "&var=val",
+		"{&list}":        "&list=red,green,blue",
+		"{&list*}":       "&list=red&list=green&list=blue",
+		"{&keys}":        "&keys=comma,%2C,dot,.,semi,%3B",
+		"{&keys*}":       "&comma=%2C&dot=.&semi=%3B",
+	}
+	for tmpl, want := range tests {
+		got, err := snowy.ExpandURI(tmpl, params)
+		if assert.Nil(t, err, tmpl) {
+			assert.Equal(t, want, got, tmpl)
+		}
+	}
+
+	t.Run("struct parameters", func(t *testing.T) {
+		type orderPath struct {
+			UserID  string `uri:"user_id"`
+			OrderID int
+			Fields  []string `uri:"fields"`
+			Secret  string   `uri:"-"`
+		}
+		got, err := snowy.ExpandURI("/users/{user_id}/orders/{OrderID}{?fields,Secret}", &orderPath{UserID: "a/b c", OrderID: 7, Fields: []string{"id", "total"}, Secret: "s"})
+		assert.Nil(t, err)
+		assert.Equal(t, "/users/a%2Fb%20c/orders/7?fields=id,total", got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for tmpl, message := range map[string]string{
+			"/users/{id":      "unclosed expression",
+			"/users/{}":       "invalid variable name",
+			"/users/{a b}":    "invalid variable name",
+			"/users/{id:0}":   "invalid prefix length",
+			"/users/{!id}":    "reserved operator",
+			"/users/{list:2}": "prefix modifier applied to a composite value",
+		} {
+			_, err := snowy.ExpandURI(tmpl, map[string]any{"id": "1", "list": []string{"a"}})
+			assert.ErrorContains(t, err, message, tmpl)
+		}
+		_, err := snowy.ExpandURI("/users/{id}", []string{"1"})
+		assert.ErrorContains(t, err, "unsupported parameters type")
+	})
+}
+
+func TestSnowyPathParams(t *testing.T) {
+	var path, rawQuery string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		rawQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	t.Run("verbs", func(t *testing.T) {
+		_, err := snowy.Get[TestResponse](snowy.Config{}, ts.URL+"/users/{id}/orders/{order}{?expand*}", nil, snowy.RequestData{
+			PathParams:  map[string]any{"id": "john doe", "order": "a/1", "expand": []string{"items", "customer"}},
+			QueryParams: map[string]string{"page": "2"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "/users/john%20doe/orders/a%2F1", path)
+		assert.Equal(t, "expand=items&expand=customer&page=2", rawQuery)
+	})
+
+	t.Run("client paths", func(t *testing.T) {
+		client := snowy.NewClient(ts.URL+"/v1", snowy.Config{})
+		_, err := snowy.ClientPost[TestResponse](client, "users/{id}/files{/path*}", nil, snowy.RequestData{
+			PathParams: map[string]any{"id": 42, "path": []string{"docs", "a b.txt"}},
+			JsonData:   FakeUser{ID: "42"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "/v1/users/42/files/docs/a%20b.txt", path)
+	})
+
+	t.Run("invalid templates", func(t *testing.T) {
+		_, err := snowy.Delete[TestResponse](snowy.Config{}, ts.URL+"/users/{id", nil, snowy.RequestData{PathParams: map[string]string{"id": "1"}})
+		assert.ErrorContains(t, err, "unclosed expression")
+	})
+}
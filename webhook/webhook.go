@@ -0,0 +1,297 @@
+// Package webhook signs outgoing webhooks sent with snowy and verifies incoming
+// ones, using HMAC-SHA256 signatures in the style of Stripe or GitHub.
+//
+// Sending a signed event:
+//
+//	signer := &webhook.Signer{Secret: []byte("whsec_..."), Scheme: webhook.Stripe}
+//	response, err := webhook.Send[Ack](snowy.Config{}, signer, "https://example.com/webhooks", OrderEvent{ID: "ord_1"})
+//
+// Receiving events, rejecting invalid signatures, stale timestamps and replays:
+//
+//	verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("whsec_...")}, Scheme: webhook.Stripe}
+//	http.Handle("/webhooks", webhook.Handler(verifier, func(w http.ResponseWriter, r *http.Request, event *OrderEvent) {
+//		w.WriteHeader(http.StatusNoContent)
+//	}))
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+)
+
+var (
+	// ErrInvalidSignature is returned when no signature matches the payload.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrTimestampOutOfTolerance is returned when the signed timestamp is too old or too far in the future.
+	ErrTimestampOutOfTolerance = errors.New("webhook: timestamp outside of tolerance")
+	// ErrReplayed is returned when a webhook was already received.
+	ErrReplayed = errors.New("webhook: replayed delivery")
+)
+
+// Scheme defines how a signature is computed and carried in headers.
+type Scheme interface {
+	// Sign returns the headers carrying the signature of body.
+	Sign(secret []byte, timestamp time.Time, body []byte) map[string]string
+	// Verify checks the signature in header against body. It returns the signed
+	// timestamp, zero when the scheme has none, and an identifier of the delivery
+	// used for replay protection.
+	Verify(secret []byte, header http.Header, body []byte) (timestamp time.Time, id string, err error)
+}
+
+var (
+	// Stripe signs "timestamp.body" and sends "Stripe-Signature: t=<timestamp>,v1=<hex signature>".
+	Stripe Scheme = stripeScheme{}
+	// GitHub signs the body and sends "X-Hub-Signature-256: sha256=<hex signature>".
+	// The scheme has no signed timestamp or delivery ID, so replays of a body are
+	// only rejected while it is remembered by the ReplayCache, twice the tolerance.
+	GitHub Scheme = githubScheme{}
+)
+
+type stripeScheme struct{}
+
+func (stripeScheme) Sign(secret []byte, timestamp time.Time, body []byte) map[string]string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return map[string]string{"Stripe-Signature": "t=" + t + ",v1=" + hexHMAC(secret, []byte(t+"."), body)}
+}
+
+func (stripeScheme) Verify(secret []byte, header http.Header, body []byte) (time.Time, string, error) {
+	value := header.Get("Stripe-Signature")
+	var t string
+	var signatures []string
+	for _, pair := range strings.Split(value, ",") {
+		key, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		switch key {
+		case "t":
+			t = v
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+	seconds, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return time.Time{}, "", fmt.Errorf("%w: malformed Stripe-Signature header", ErrInvalidSignature)
+	}
+	expected := hexHMAC(secret, []byte(t+"."), body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return time.Unix(seconds, 0), t + "." + signature, nil
+		}
+	}
+	return time.Time{}, "", ErrInvalidSignature
+}
+
+type githubScheme struct{}
+
+func (githubScheme) Sign(secret []byte, _ time.Time, body []byte) map[string]string {
+	return map[string]string{"X-Hub-Signature-256": "sha256=" + hexHMAC(secret, nil, body)}
+}
+
+func (githubScheme) Verify(secret []byte, header http.Header, body []byte) (time.Time, string, error) {
+	signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("%w: malformed X-Hub-Signature-256 header", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(signature), []byte(hexHMAC(secret, nil, body))) {
+		return time.Time{}, "", ErrInvalidSignature
+	}
+	// X-GitHub-Delivery is not signed, so the signature identifies the delivery.
+	return time.Time{}, signature, nil
+}
+
+func hexHMAC(secret, prefix, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(prefix)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signer signs the body of outgoing requests.
+type Signer struct {
+	Secret []byte
+	Scheme Scheme // Defaults to Stripe
+}
+
+// Middleware signs every attempt of a request, with a fresh timestamp.
+func (s *Signer) Middleware() snowy.Middleware {
+	return func(next snowy.RoundTripFunc) snowy.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			body, err := readBody(req)
+			if err != nil {
+				return nil, fmt.Errorf("reading webhook body: %w", err)
+			}
+			for k, v := range s.scheme().Sign(s.Secret, time.Now(), body) {
+				req.Header.Set(k, v)
+			}
+			return next(req)
+		}
+	}
+}
+
+func (s *Signer) scheme() Scheme {
+	if s.Scheme == nil {
+		return Stripe
+	}
+	return s.Scheme
+}
+
+// Send posts payload as JSON to url, signed by signer, and decodes the response
+// into T like snowy.Post.
+func Send[T any](config snowy.Config, signer *Signer, url string, payload any) (*snowy.Response[T], error) {
+	config.Middleware = append(slices.Clip(config.Middleware), signer.Middleware())
+	return snowy.Post[T](config, url, nil, snowy.RequestData{JsonData: payload})
+}
+
+// readBody returns the body of req without consuming it.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// ReplayCache remembers delivered webhooks.
+type ReplayCache interface {
+	// Seen reports whether id was already recorded, and records it until expires otherwise.
+	Seen(id string, expires time.Time) bool
+}
+
+// MemoryReplayCache is an in-memory ReplayCache.
+type MemoryReplayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{seen: make(map[string]time.Time)}
+}
+
+func (c *MemoryReplayCache) Seen(id string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, until := range c.seen {
+		if now.After(until) {
+			delete(c.seen, key)
+		}
+	}
+	if _, ok := c.seen[id]; ok {
+		return true
+	}
+	c.seen[id] = expires
+	return false
+}
+
+// Verifier verifies incoming webhooks.
+type Verifier struct {
+	Secrets      [][]byte      // Accepted secrets, more than one while rotating
+	Scheme       Scheme        // Defaults to Stripe
+	Tolerance    time.Duration // Maximum difference between the signed timestamp and now, defaults to 5 minutes
+	Replay       ReplayCache   // Defaults to an in-memory cache; deliveries are remembered for twice the tolerance
+	MaxBodyBytes int64         // Defaults to 1 MiB
+
+	once sync.Once
+}
+
+// Verify checks the signature, timestamp and uniqueness of a delivery.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	v.once.Do(func() {
+		if v.Replay == nil {
+			v.Replay = NewMemoryReplayCache()
+		}
+	})
+	scheme := v.Scheme
+	if scheme == nil {
+		scheme = Stripe
+	}
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = 5 * time.Minute
+	}
+
+	err := ErrInvalidSignature
+	var timestamp time.Time
+	var id string
+	for _, secret := range v.Secrets {
+		if timestamp, id, err = scheme.Verify(secret, header, body); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !timestamp.IsZero() && (now.Sub(timestamp) > tolerance || timestamp.Sub(now) > tolerance) {
+		return ErrTimestampOutOfTolerance
+	}
+	if v.Replay.Seen(id, now.Add(2*tolerance)) {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// Handler verifies incoming webhooks and decodes their JSON payload into T before
+// calling handle. An empty body is passed as a nil payload. Requests with invalid
+// signatures, timestamps outside of the tolerance or replayed deliveries are
+// answered with 401 Unauthorized, and undecodable payloads with 400 Bad Request.
+func Handler[T any](v *Verifier, handle func(w http.ResponseWriter, r *http.Request, payload *T)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := v.MaxBodyBytes
+		if maxBytes == 0 {
+			maxBytes = 1 << 20
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "reading payload", http.StatusBadRequest)
+			return
+		}
+		if err := v.Verify(r.Header, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var payload T
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
+			if err == io.EOF {
+				handle(w, r, nil)
+				return
+			}
+			http.Error(w, "decoding payload", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handle(w, r, &payload)
+	})
+}
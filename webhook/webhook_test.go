@@ -0,0 +1,121 @@
+package webhook_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brunobolting/go-snowy"
+	"github.com/brunobolting/go-snowy/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type OrderEvent struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+}
+
+type Ack struct {
+	Received string `json:"received"`
+}
+
+func stripeHeader(secret string, timestamp time.Time, body string) string {
+	t := fmt.Sprint(timestamp.Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "." + body))
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhook(t *testing.T) {
+	t.Run("send and receive", func(t *testing.T) {
+		for name, scheme := range map[string]webhook.Scheme{"stripe": webhook.Stripe, "github": webhook.GitHub} {
+			t.Run(name, func(t *testing.T) {
+				verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("old"), []byte("secret")}, Scheme: scheme}
+				ts := httptest.NewServer(webhook.Handler(verifier, func(w http.ResponseWriter, r *http.Request, event *OrderEvent) {
+					assert.Equal(t, OrderEvent{ID: "ord_1", Amount: 1200}, *event)
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprintf(w, `{"received":%q}`, event.ID)
+				}))
+				defer ts.Close()
+
+				signer := &webhook.Signer{Secret: []byte("secret"), Scheme: scheme}
+				res, err := webhook.Send[Ack](snowy.Config{}, signer, ts.URL, OrderEvent{ID: "ord_1", Amount: 1200})
+				assert.Nil(t, err)
+				assert.Equal(t, "ord_1", res.Data.Received)
+			})
+		}
+	})
+
+	t.Run("stripe signature header", func(t *testing.T) {
+		body := `{"id":"evt_1"}`
+		now := time.Now()
+		headers := webhook.Stripe.Sign([]byte("whsec"), now, []byte(body))
+		assert.Equal(t, stripeHeader("whsec", now, body), headers["Stripe-Signature"])
+	})
+
+	t.Run("rejects invalid signatures", func(t *testing.T) {
+		called := false
+		verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("secret")}}
+		handler := webhook.Handler(verifier, func(w http.ResponseWriter, r *http.Request, event *OrderEvent) {
+			called = true
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"ord_1"}`))
+		req.Header.Set("Stripe-Signature", stripeHeader("wrong", time.Now(), `{"id":"ord_1"}`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.False(t, called)
+	})
+
+	t.Run("rejects timestamps outside of tolerance", func(t *testing.T) {
+		verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("secret")}, Tolerance: time.Minute}
+		header := http.Header{}
+		header.Set("Stripe-Signature", stripeHeader("secret", time.Now().Add(-2*time.Minute), `{}`))
+		assert.ErrorIs(t, verifier.Verify(header, []byte(`{}`)), webhook.ErrTimestampOutOfTolerance)
+	})
+
+	t.Run("rejects replayed deliveries", func(t *testing.T) {
+		verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("secret")}, Scheme: webhook.GitHub}
+		header := http.Header{}
+		for k, v := range webhook.GitHub.Sign([]byte("secret"), time.Time{}, []byte(`{}`)) {
+			header.Set(k, v)
+		}
+		header.Set("X-GitHub-Delivery", "delivery-1")
+		assert.Nil(t, verifier.Verify(header, []byte(`{}`)))
+		assert.ErrorIs(t, verifier.Verify(header, []byte(`{}`)), webhook.ErrReplayed)
+
+		header.Set("X-GitHub-Delivery", "delivery-2")
+		assert.ErrorIs(t, verifier.Verify(header, []byte(`{}`)), webhook.ErrReplayed)
+	})
+
+	t.Run("rejects undecodable payloads", func(t *testing.T) {
+		verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("secret")}}
+		handler := webhook.Handler(verifier, func(w http.ResponseWriter, r *http.Request, event *OrderEvent) {
+			t.Fail()
+		})
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`not json`))
+		req.Header.Set("Stripe-Signature", stripeHeader("secret", time.Now(), `not json`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("rejects large payloads", func(t *testing.T) {
+		verifier := &webhook.Verifier{Secrets: [][]byte{[]byte("secret")}, MaxBodyBytes: 8}
+		handler := webhook.Handler(verifier, func(w http.ResponseWriter, r *http.Request, event *OrderEvent) {
+			t.Fail()
+		})
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"ord_123456"}`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}